@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// traefikAccessLog holds fields of traefik JSON access log, see
+// https://doc.traefik.io/traefik/observability/access-logs/#json-format
+type traefikAccessLog struct {
+	StartUTC              time.Time `json:"StartUTC"`
+	ClientHost            string    `json:"ClientHost"`
+	RequestHost           string    `json:"RequestHost"`
+	RequestMethod         string    `json:"RequestMethod"`
+	RequestPath           string    `json:"RequestPath"`
+	RouterName            string    `json:"RouterName"`
+	ServiceName           string    `json:"ServiceName"`
+	DownstreamStatus      int       `json:"DownstreamStatus"`
+	DownstreamContentSize int64     `json:"DownstreamContentSize"`
+	Duration              int64     `json:"Duration"` // nanoseconds
+}
+
+type AccessLogEntry struct {
+	Time       time.Time     `json:"time"`
+	Client     string        `json:"client"`
+	Host       string        `json:"host"`
+	Method     string        `json:"method"`
+	Path       string        `json:"path"`
+	Router     string        `json:"router"`
+	Service    string        `json:"service"`
+	Status     int           `json:"status"`
+	Size       int64         `json:"size"`
+	Duration   time.Duration `json:"-"`
+	DurationMs float64       `json:"duration_ms"`
+}
+
+// parseAccessLogLine returns false for lines other than JSON access log, eg. traefik own logs.
+func parseAccessLogLine(line []byte) (AccessLogEntry, bool) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 || line[0] != '{' {
+		return AccessLogEntry{}, false
+	}
+
+	var raw traefikAccessLog
+	if err := json.Unmarshal(line, &raw); err != nil || raw.StartUTC.IsZero() || raw.RequestMethod == "" {
+		return AccessLogEntry{}, false
+	}
+
+	duration := time.Duration(raw.Duration)
+
+	return AccessLogEntry{
+		Time:       raw.StartUTC,
+		Client:     raw.ClientHost,
+		Host:       raw.RequestHost,
+		Method:     raw.RequestMethod,
+		Path:       raw.RequestPath,
+		Router:     raw.RouterName,
+		Service:    raw.ServiceName,
+		Status:     raw.DownstreamStatus,
+		Size:       raw.DownstreamContentSize,
+		Duration:   duration,
+		DurationMs: float64(duration.Microseconds()) / 1000,
+	}, true
+}
+
+// streamAccessLog feeds access log entries of traefik container to fn, until ctx is done.
+func streamAccessLog(ctx context.Context, follow bool, since, tail string, fn func(AccessLogEntry) error) error {
+	args := []string{"logs", "--no-color", "--no-log-prefix"}
+	if follow {
+		args = append(args, "--follow")
+	}
+	if since != "" {
+		args = append(args, "--since", since)
+	}
+	if tail != "" {
+		args = append(args, "--tail", tail)
+	}
+	args = append(args, "traefik")
+
+	cmd, err := dockerComposeCommand(args...)
+	if err != nil {
+		return err
+	}
+
+	cmd.Stderr = os.Stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	stop := context.AfterFunc(ctx, func() { cmd.Process.Kill() })
+	defer stop()
+
+	err = scanAccessLog(stdout, fn)
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+
+	if err := cmd.Wait(); err != nil && ctx.Err() == nil {
+		return err
+	}
+
+	return nil
+}
+
+func scanAccessLog(r io.Reader, fn func(AccessLogEntry) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		entry, ok := parseAccessLogLine(scanner.Bytes())
+		if !ok {
+			continue
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+type AccessLogFilter struct {
+	Hosts       []string // glob patterns, eg. *.local.test
+	Routers     []string // with or without @provider suffix
+	Statuses    []string // status class, eg. 5xx, or exact code
+	Path        *regexp.Regexp
+	MinDuration time.Duration
+}
+
+func validateStatusFilter(status string) error {
+	if len(status) == 3 && status[0] >= '1' && status[0] <= '5' && strings.ToLower(status[1:]) == "xx" {
+		return nil
+	}
+	if code, err := strconv.Atoi(status); err == nil && code >= 100 && code <= 599 {
+		return nil
+	}
+	return fmt.Errorf("invalid status %q, expected class like 4xx or exact code like 404", status)
+}
+
+func matchAny(patterns []string, fn func(string) bool) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if fn(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *AccessLogFilter) Match(e AccessLogEntry) bool {
+	host, _, _ := strings.Cut(e.Host, ":")
+
+	if !matchAny(f.Hosts, func(p string) bool {
+		ok, _ := path.Match(strings.ToLower(p), strings.ToLower(host))
+		return ok
+	}) {
+		return false
+	}
+
+	if !matchAny(f.Routers, func(p string) bool {
+		name, _, _ := strings.Cut(e.Router, "@")
+		return p == e.Router || p == name
+	}) {
+		return false
+	}
+
+	code := strconv.Itoa(e.Status)
+	if !matchAny(f.Statuses, func(p string) bool {
+		if strings.HasSuffix(strings.ToLower(p), "xx") {
+			return code[:1] == p[:1]
+		}
+		return p == code
+	}) {
+		return false
+	}
+
+	if f.Path != nil && !f.Path.MatchString(e.Path) {
+		return false
+	}
+
+	return e.Duration >= f.MinDuration
+}
+
+func accessLogFilterFromFlags(cmd *cobra.Command) (*AccessLogFilter, error) {
+	var f AccessLogFilter
+
+	f.Hosts, _ = cmd.Flags().GetStringSlice("host")
+	f.Routers, _ = cmd.Flags().GetStringSlice("router")
+	f.Statuses, _ = cmd.Flags().GetStringSlice("status")
+	f.MinDuration, _ = cmd.Flags().GetDuration("min-duration")
+
+	for _, status := range f.Statuses {
+		if err := validateStatusFilter(status); err != nil {
+			return nil, err
+		}
+	}
+
+	if expr, _ := cmd.Flags().GetString("path"); expr != "" {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path regex: %w", err)
+		}
+		f.Path = re
+	}
+
+	return &f, nil
+}
+
+const accessLogTableFormat = "%-12s %-6s %9s  %-6s %-30s %-24s %s\n"
+
+func printAccessLogHeader() {
+	fmt.Printf(accessLogTableFormat, "TIME", "STATUS", "DURATION", "METHOD", "HOST", "ROUTER", "PATH")
+}
+
+func printAccessLogEntry(e AccessLogEntry) {
+	fmt.Printf(accessLogTableFormat,
+		e.Time.Local().Format("15:04:05.000"), strconv.Itoa(e.Status), renderDuration(e.Duration),
+		e.Method, e.Host, e.Router, e.Path)
+}
+
+func renderDuration(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return fmt.Sprintf("%.2fs", d.Seconds())
+	case d >= time.Millisecond:
+		return fmt.Sprintf("%.1fms", float64(d.Microseconds())/1000)
+	}
+	return fmt.Sprintf("%dµs", d.Microseconds())
+}
+
+var cmdLogsAccess = &cobra.Command{
+	Use:   "access",
+	Short: "Show traefik access logs, filtered and formatted",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filter, err := accessLogFilterFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		follow, _ := cmd.Flags().GetBool("follow")
+		since, _ := cmd.Flags().GetString("since")
+		tail, _ := cmd.Flags().GetString("tail")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		enc := json.NewEncoder(os.Stdout)
+
+		if !asJSON {
+			printAccessLogHeader()
+		}
+
+		return streamAccessLog(cmd.Context(), follow, since, tail, func(e AccessLogEntry) error {
+			if !filter.Match(e) {
+				return nil
+			}
+			if asJSON {
+				return enc.Encode(e)
+			}
+			printAccessLogEntry(e)
+			return nil
+		})
+	},
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+const accessLogLine = `{"ClientHost":"172.18.0.1","DownstreamContentSize":42,"DownstreamStatus":502,"Duration":1500000,` +
+	`"RequestHost":"app.my.test:443","RequestMethod":"POST","RequestPath":"/api/orders?id=1",` +
+	`"RouterName":"app@docker","ServiceName":"app@docker","StartUTC":"2025-01-02T03:04:05.123456789Z"}`
+
+func TestParseAccessLogLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		ok   bool
+	}{
+		{name: "access log", line: accessLogLine, ok: true},
+		{name: "surrounding whitespace", line: "  " + accessLogLine + "\r\n", ok: true},
+		{name: "empty", line: "", ok: false},
+		{name: "traefik own log", line: `2025-01-02T03:04:05Z INF Starting provider *docker.Provider`, ok: false},
+		{name: "traefik own json log", line: `{"level":"info","time":"2025-01-02T03:04:05Z","message":"Starting"}`, ok: false},
+		{name: "truncated json", line: accessLogLine[:len(accessLogLine)/2], ok: false},
+		{name: "wrong field type", line: `{"StartUTC":"2025-01-02T03:04:05Z","RequestMethod":"GET","DownstreamStatus":"200"}`, ok: false},
+		{name: "invalid time", line: `{"StartUTC":"yesterday","RequestMethod":"GET"}`, ok: false},
+		{name: "missing method", line: `{"StartUTC":"2025-01-02T03:04:05Z","RequestHost":"local.test"}`, ok: false},
+		{name: "json array", line: `[1,2,3]`, ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, ok := parseAccessLogLine([]byte(tt.line))
+			if ok != tt.ok {
+				t.Fatalf("parseAccessLogLine() ok = %v, want %v (entry %+v)", ok, tt.ok, entry)
+			}
+			if !ok {
+				return
+			}
+
+			want := AccessLogEntry{
+				Time:       time.Date(2025, 1, 2, 3, 4, 5, 123456789, time.UTC),
+				Client:     "172.18.0.1",
+				Host:       "app.my.test:443",
+				Method:     "POST",
+				Path:       "/api/orders?id=1",
+				Router:     "app@docker",
+				Service:    "app@docker",
+				Status:     502,
+				Size:       42,
+				Duration:   1500 * time.Microsecond,
+				DurationMs: 1.5,
+			}
+			if entry != want {
+				t.Errorf("parseAccessLogLine() = %+v, want %+v", entry, want)
+			}
+		})
+	}
+}
+
+func TestScanAccessLog(t *testing.T) {
+	input := strings.Join([]string{
+		"time=2025-01-02 level=info msg=Configuration loaded",
+		accessLogLine,
+		`{"level":"warn"}`,
+		"",
+		`{"StartUTC":"2025-01-02T03:04:06Z",`,
+		accessLogLine,
+	}, "\n")
+
+	count := 0
+	err := scanAccessLog(strings.NewReader(input), func(AccessLogEntry) error {
+		count++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("scanAccessLog() error: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("scanAccessLog() emitted %d entries, want 2", count)
+	}
+}
+
+func TestAccessLogFilterMatch(t *testing.T) {
+	entry, ok := parseAccessLogLine([]byte(accessLogLine))
+	if !ok {
+		t.Fatal("parseAccessLogLine() failed")
+	}
+
+	tests := []struct {
+		name   string
+		filter AccessLogFilter
+		want   bool
+	}{
+		{name: "no filter", filter: AccessLogFilter{}, want: true},
+		{name: "host glob ignores port", filter: AccessLogFilter{Hosts: []string{"*.my.test"}}, want: true},
+		{name: "host glob case insensitive", filter: AccessLogFilter{Hosts: []string{"APP.*"}}, want: true},
+		{name: "host mismatch", filter: AccessLogFilter{Hosts: []string{"*.local.test"}}, want: false},
+		{name: "any of hosts", filter: AccessLogFilter{Hosts: []string{"*.local.test", "app.my.test"}}, want: true},
+		{name: "router without provider", filter: AccessLogFilter{Routers: []string{"app"}}, want: true},
+		{name: "router with provider", filter: AccessLogFilter{Routers: []string{"app@docker"}}, want: true},
+		{name: "router mismatch", filter: AccessLogFilter{Routers: []string{"app@file"}}, want: false},
+		{name: "status class", filter: AccessLogFilter{Statuses: []string{"5xx"}}, want: true},
+		{name: "status class upper case", filter: AccessLogFilter{Statuses: []string{"5XX"}}, want: true},
+		{name: "status code", filter: AccessLogFilter{Statuses: []string{"502"}}, want: true},
+		{name: "status mismatch", filter: AccessLogFilter{Statuses: []string{"4xx", "500"}}, want: false},
+		{name: "path regexp", filter: AccessLogFilter{Path: regexp.MustCompile(`^/api/`)}, want: true},
+		{name: "path mismatch", filter: AccessLogFilter{Path: regexp.MustCompile(`^/admin`)}, want: false},
+		{name: "min duration reached", filter: AccessLogFilter{MinDuration: time.Millisecond}, want: true},
+		{name: "min duration not reached", filter: AccessLogFilter{MinDuration: 2 * time.Millisecond}, want: false},
+		{
+			name: "all filters",
+			filter: AccessLogFilter{
+				Hosts:       []string{"*.my.test"},
+				Routers:     []string{"app"},
+				Statuses:    []string{"5xx"},
+				Path:        regexp.MustCompile(`orders`),
+				MinDuration: time.Millisecond,
+			},
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(entry); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateStatusFilter(t *testing.T) {
+	tests := []struct {
+		status  string
+		wantErr bool
+	}{
+		{status: "2xx"},
+		{status: "5XX"},
+		{status: "404"},
+		{status: "599"},
+		{status: "6xx", wantErr: true},
+		{status: "0xx", wantErr: true},
+		{status: "99", wantErr: true},
+		{status: "600", wantErr: true},
+		{status: "5x", wantErr: true},
+		{status: "abc", wantErr: true},
+		{status: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			if err := validateStatusFilter(tt.status); (err != nil) != tt.wantErr {
+				t.Errorf("validateStatusFilter(%q) error = %v, want error %v", tt.status, err, tt.wantErr)
+			}
+		})
+	}
+}
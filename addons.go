@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// addons are optional compose fragments embedded as addons/<name>.yaml,
+// merged into the stack once enabled via ADDONS setting.
+const addonsSetting = "ADDONS"
+
+type Addon struct {
+	Name        string
+	Description string // first comment line of fragment
+}
+
+func (a Addon) Path() string {
+	return "addons/" + a.Name + ".yaml"
+}
+
+// stackAddons lists addons embedded into binary, sorted by name.
+func stackAddons() []Addon {
+	var addons []Addon
+
+	for _, file := range stackFilesMeta {
+		if path.Dir(file.Path) != "addons" || path.Ext(file.Path) != ".yaml" {
+			continue
+		}
+
+		addon := Addon{Name: strings.TrimSuffix(path.Base(file.Path), ".yaml")}
+
+		if data, err := stackFilesFS.ReadFile(file.Path); err == nil {
+			line, _, _ := bytes.Cut(data, []byte("\n"))
+			if desc, ok := strings.CutPrefix(string(line), "#"); ok {
+				addon.Description = strings.TrimSpace(desc)
+			}
+		}
+
+		addons = append(addons, addon)
+	}
+
+	sort.Slice(addons, func(i, j int) bool { return addons[i].Name < addons[j].Name })
+
+	return addons
+}
+
+func lookupAddon(name string) (Addon, error) {
+	for _, addon := range stackAddons() {
+		if addon.Name == name {
+			return addon, nil
+		}
+	}
+	return Addon{}, fmt.Errorf("unknown addon %q, see 'addons list' command", name)
+}
+
+func parseAddons(value string) ([]string, error) {
+	var names []string
+
+	for _, name := range strings.Fields(value) {
+		if _, err := lookupAddon(name); err != nil {
+			return nil, err
+		}
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// enabledAddons returns addons enabled via settings, skipping those not
+// embedded anymore, eg. after upgrade.
+func enabledAddons() []Addon {
+	value, _ := settingValue(addonsSetting)
+
+	var addons []Addon
+	for _, name := range strings.Fields(value) {
+		addon, err := lookupAddon(name)
+		if err != nil {
+			fmt.Printf("WARN: %s: %v, skipping.\n", addonsSetting, err)
+			continue
+		}
+		if !slices.Contains(addons, addon) {
+			addons = append(addons, addon)
+		}
+	}
+
+	return addons
+}
+
+// addonComposeArgs returns compose file arguments of enabled addons.
+func addonComposeArgs(dest string) ([]string, error) {
+	var args []string
+
+	for _, addon := range enabledAddons() {
+		fragment := filepath.Join(dest, filepath.FromSlash(addon.Path()))
+		if !fileExists(fragment) {
+			return nil, fmt.Errorf("addon %q is missing in %s, please run 'sync' command", addon.Name, dest)
+		}
+		args = append(args, "-f", fragment)
+	}
+
+	return args, nil
+}
+
+func updateAddons(args []string, enable bool) error {
+	var names []string
+	for _, addon := range enabledAddons() {
+		names = append(names, addon.Name)
+	}
+
+	for _, name := range args {
+		if _, err := lookupAddon(name); err != nil {
+			return err
+		}
+
+		enabled := slices.Contains(names, name)
+
+		switch {
+		case enable && enabled:
+			fmt.Printf("Addon %q is already enabled\n", name)
+		case enable:
+			names = append(names, name)
+			fmt.Printf("Addon %q enabled\n", name)
+		case enabled:
+			names = slices.DeleteFunc(names, func(n string) bool { return n == name })
+			fmt.Printf("Addon %q disabled\n", name)
+		default:
+			fmt.Printf("Addon %q is already disabled\n", name)
+		}
+	}
+
+	sort.Strings(names)
+
+	if err := setSetting(addonsSetting, strings.Join(names, " ")); err != nil {
+		return err
+	}
+
+	fmt.Printf("\nINFO: please run 'up' command to apply changes.\n")
+
+	return nil
+}
+
+const addonsTableFormat = "%-10s %-8s %s\n"
+
+var cmdAddons = &cobra.Command{
+	Use:   "addons",
+	Short: "Manage optional stack services, such as mail catcher or object storage",
+}
+
+var cmdAddonsList = &cobra.Command{
+	Use:   "list",
+	Short: "List embedded addons",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		enabled := enabledAddons()
+
+		fmt.Printf(addonsTableFormat, "NAME", "ENABLED", "DESCRIPTION")
+		for _, addon := range stackAddons() {
+			status := "no"
+			if slices.Contains(enabled, addon) {
+				status = "yes"
+			}
+
+			fmt.Printf(addonsTableFormat, addon.Name, status, addon.Description)
+		}
+
+		return nil
+	},
+}
+
+var cmdAddonsEnable = &cobra.Command{
+	Use:   "enable <name>...",
+	Short: "Include addons into the stack",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateAddons(args, true)
+	},
+}
+
+var cmdAddonsDisable = &cobra.Command{
+	Use:   "disable <name>...",
+	Short: "Exclude addons from the stack, its containers are removed on 'up'",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateAddons(args, false)
+	},
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// archiveSumsFile is the last entry of every archive, listing regular files.
+const archiveSumsFile = "SHA256SUMS"
+
+var ErrArchiveInvalid = errors.New("archive invalid")
+
+// archiveWriter writes tar entries and records checksums of regular files.
+type archiveWriter struct {
+	tw   *tar.Writer
+	sums map[string]string
+	now  time.Time
+}
+
+func newArchiveWriter(w io.Writer) *archiveWriter {
+	return &archiveWriter{tw: tar.NewWriter(w), sums: map[string]string{}, now: time.Now()}
+}
+
+func (w *archiveWriter) writeEntry(hdr *tar.Header, r io.Reader) error {
+	if err := w.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	if hdr.Typeflag != tar.TypeReg {
+		return nil
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(w.tw, h), r); err != nil {
+		return fmt.Errorf("failed to archive %q: %w", hdr.Name, err)
+	}
+
+	w.sums[hdr.Name] = hex.EncodeToString(h.Sum(nil))
+
+	return nil
+}
+
+func (w *archiveWriter) addFile(name, src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     int64(fi.Mode().Perm()),
+		Size:     fi.Size(),
+		ModTime:  fi.ModTime(),
+	}
+
+	return w.writeEntry(hdr, f)
+}
+
+func (w *archiveWriter) addBytes(name string, mode int64, data []byte) error {
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     mode,
+		Size:     int64(len(data)),
+		ModTime:  w.now,
+	}
+
+	return w.writeEntry(hdr, strings.NewReader(string(data)))
+}
+
+// Close writes checksums entry and flushes the archive.
+func (w *archiveWriter) Close() error {
+	names := make([]string, 0, len(w.sums))
+	for name := range w.sums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s  %s\n", w.sums[name], name)
+	}
+
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     archiveSumsFile,
+		Mode:     0o644,
+		Size:     int64(b.Len()),
+		ModTime:  w.now,
+	}
+
+	if err := w.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w.tw, b.String()); err != nil {
+		return err
+	}
+
+	return w.tw.Close()
+}
+
+// createArchive writes gzipped archive atomically via temp file.
+func createArchive(file string, fn func(w *archiveWriter) error) error {
+	tmpPath := file + ".tmp"
+
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+	defer f.Close()
+
+	gw := gzip.NewWriter(f)
+	w := newArchiveWriter(gw)
+
+	if err := fn(w); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, file)
+}
+
+// walkArchive iterates over gzipped archive entries.
+func walkArchive(file string, fn func(hdr *tar.Header, r io.Reader) error) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrArchiveInvalid, err)
+	}
+	defer gr.Close()
+
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrArchiveInvalid, err)
+		}
+
+		if err := fn(hdr, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// archiveNameSafe rejects absolute and parent-relative entry names.
+func archiveNameSafe(name string) bool {
+	clean := path.Clean(name)
+	return clean == strings.TrimSuffix(name, "/") && !path.IsAbs(clean) && clean != ".." && !strings.HasPrefix(clean, "../")
+}
+
+func parseArchiveSums(r io.Reader) (map[string]string, error) {
+	sums := map[string]string{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		sum, name, ok := strings.Cut(scanner.Text(), "  ")
+		if !ok {
+			return nil, fmt.Errorf("%w: malformed %s line %q", ErrArchiveInvalid, archiveSumsFile, scanner.Text())
+		}
+		sums[name] = sum
+	}
+
+	return sums, scanner.Err()
+}
+
+// verifyArchive validates every regular file against SHA256SUMS entry.
+// Each entry is passed to visit first, which may consume the reader partially.
+func verifyArchive(file string, visit func(hdr *tar.Header, r io.Reader) error) error {
+	actual := map[string]string{}
+	var expected map[string]string
+
+	err := walkArchive(file, func(hdr *tar.Header, r io.Reader) error {
+		if !archiveNameSafe(hdr.Name) {
+			return fmt.Errorf("%w: unsafe entry %q", ErrArchiveInvalid, hdr.Name)
+		}
+
+		if expected != nil {
+			return fmt.Errorf("%w: entry %q after %s", ErrArchiveInvalid, hdr.Name, archiveSumsFile)
+		}
+
+		if hdr.Name == archiveSumsFile {
+			var err error
+			expected, err = parseArchiveSums(r)
+			return err
+		}
+
+		h := sha256.New()
+		tee := io.TeeReader(r, h)
+
+		if err := visit(hdr, tee); err != nil {
+			return err
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			return nil
+		}
+
+		if _, err := io.Copy(io.Discard, tee); err != nil {
+			return fmt.Errorf("%w: %w", ErrArchiveInvalid, err)
+		}
+
+		actual[hdr.Name] = hex.EncodeToString(h.Sum(nil))
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if expected == nil {
+		return fmt.Errorf("%w: %s is missing", ErrArchiveInvalid, archiveSumsFile)
+	}
+
+	failures := 0
+	for name, sum := range actual {
+		if want, ok := expected[name]; !ok {
+			fmt.Printf("%s: NOT LISTED\n", name)
+			failures++
+		} else if want != sum {
+			fmt.Printf("%s: FAILED\n", name)
+			failures++
+		}
+	}
+	for name := range expected {
+		if _, ok := actual[name]; !ok {
+			fmt.Printf("%s: MISSING\n", name)
+			failures++
+		}
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("%w: %d checksum(s) did NOT match", ErrArchiveInvalid, failures)
+	}
+
+	return nil
+}
+
+// extractArchiveFile writes single regular file entry from archive to dst.
+func extractArchiveFile(file, name, dst string) error {
+	found := false
+
+	err := walkArchive(file, func(hdr *tar.Header, r io.Reader) error {
+		if hdr.Name != name {
+			return nil
+		}
+		found = true
+
+		if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+			return err
+		}
+
+		tmpPath := dst + ".tmp"
+		f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(hdr.Mode).Perm())
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmpPath)
+
+		if _, err := io.Copy(f, r); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+
+		return os.Rename(tmpPath, dst)
+	})
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return fmt.Errorf("%w: %q not found", ErrArchiveInvalid, name)
+	}
+
+	return nil
+}
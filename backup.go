@@ -0,0 +1,397 @@
+package main
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// backup archive layout
+const (
+	backupStackPrefix   = "stack/"
+	backupConfigPrefix  = "config/"
+	backupVolumesPrefix = "volumes/"
+)
+
+const portainerVolume = "localtest_portainer"
+
+func (w *archiveWriter) addVolume(volume string) error {
+	prefix := backupVolumesPrefix + volume + "/"
+
+	return exportVolume(volume, func(hdr *tar.Header, r io.Reader) error {
+		name := path.Clean(hdr.Name)
+
+		hdr.Name = prefix
+		if name != "." {
+			hdr.Name += name
+			if hdr.Typeflag == tar.TypeDir {
+				hdr.Name += "/"
+			}
+		}
+		if hdr.Typeflag == tar.TypeLink {
+			hdr.Linkname = prefix + path.Clean(hdr.Linkname)
+		}
+
+		return w.writeEntry(hdr, r)
+	})
+}
+
+func backupVolumes(portainer bool) []string {
+	volumes := []string{stackCertsVolume, dnsVolume}
+	if portainer {
+		volumes = append(volumes, portainerVolume)
+	}
+	return volumes
+}
+
+func localCARoot() (string, error) {
+	out, err := exec.Command("mkcert", "-CAROOT").Output()
+	if err != nil {
+		return "", fmt.Errorf("mkcert -CAROOT failed: %w", err)
+	}
+	return filepath.Clean(strings.TrimSpace(string(out))), nil
+}
+
+func createBackup(file string, portainer bool) error {
+	dest := stackDir()
+
+	if !fileExists(filepath.Join(dest, stackInfoFile)) {
+		return fmt.Errorf("%w in %s", ErrStackNotExist, dest)
+	}
+
+	if err := ensureStackImage("mkcert"); err != nil {
+		return err
+	}
+
+	return createArchive(file, func(w *archiveWriter) error {
+		if err := w.addFile(backupStackPrefix+stackInfoFile, filepath.Join(dest, stackInfoFile)); err != nil {
+			return err
+		}
+
+		cas, err := filepath.Glob(filepath.Join(dest, "certs", "rootCA*.pem"))
+		if err != nil {
+			return err
+		}
+		for _, src := range cas {
+			if err := w.addFile(backupStackPrefix+"certs/"+filepath.Base(src), src); err != nil {
+				return err
+			}
+		}
+
+		if fileExists(settingsPath()) {
+			if err := w.addFile(backupConfigPrefix+filepath.Base(settingsPath()), settingsPath()); err != nil {
+				return err
+			}
+		}
+
+		for _, volume := range backupVolumes(portainer) {
+			if !volumeExists(volume) {
+				fmt.Printf("WARN: volume %q does not exist, skipping\n", volume)
+				continue
+			}
+
+			fmt.Printf("Archiving volume %q ...\n", volume)
+
+			if err := w.addVolume(volume); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func backupEntryAllowed(name string) bool {
+	switch {
+	case name == backupStackPrefix+stackInfoFile:
+		return true
+	case strings.HasPrefix(name, backupStackPrefix+"certs/rootCA"):
+		return !strings.Contains(strings.TrimPrefix(name, backupStackPrefix+"certs/"), "/")
+	case name == backupConfigPrefix+filepath.Base(settingsPath()):
+		return true
+	}
+
+	for _, volume := range backupVolumes(true) {
+		if strings.HasPrefix(name, backupVolumesPrefix+volume+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// verifyBackup validates archive checksums and returns stored stack version
+// along with archived volumes.
+func verifyBackup(file string) (*StackVersion, []string, error) {
+	var sv *StackVersion
+	var volumes []string
+
+	err := verifyArchive(file, func(hdr *tar.Header, r io.Reader) error {
+		if !backupEntryAllowed(hdr.Name) {
+			return fmt.Errorf("%w: unexpected entry %q", ErrArchiveInvalid, hdr.Name)
+		}
+
+		if rest, ok := strings.CutPrefix(hdr.Name, backupVolumesPrefix); ok {
+			volume, _, _ := strings.Cut(rest, "/")
+			if len(volumes) == 0 || volumes[len(volumes)-1] != volume {
+				volumes = append(volumes, volume)
+			}
+		}
+
+		if hdr.Name != backupStackPrefix+stackInfoFile {
+			return nil
+		}
+
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrArchiveInvalid, err)
+		}
+
+		sv = &StackVersion{}
+		if err := sv.UnmarshalBinary(data); err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrArchiveInvalid, stackInfoFile, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if sv == nil {
+		return nil, nil, fmt.Errorf("%w: %s is missing", ErrArchiveInvalid, stackInfoFile)
+	}
+
+	return sv, volumes, nil
+}
+
+func checkBackupCompatibility(sv *StackVersion) error {
+	if sv.SpecVersion > stackSpecVersion {
+		return fmt.Errorf("backup stack spec v%d is newer than supported v%d, please upgrade %s", sv.SpecVersion, stackSpecVersion, appName)
+	}
+
+	if !sv.IsSameManifest() {
+		fmt.Printf("WARN: backup was made by %s %s with different stack content, current is %s\n", appName, sv.Binary.Version, buildVersion)
+	}
+
+	return nil
+}
+
+// restoreLocalCA installs CA from backup into mkcert CAROOT, keeping the
+// same CA trusted across machines.
+func restoreLocalCA(file string) error {
+	caRoot, err := localCARoot()
+	if err != nil {
+		return err
+	}
+
+	tmpDir, err := os.MkdirTemp("", appName+"-ca-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	changed := false
+
+	for _, name := range []string{"rootCA.pem", "rootCA-key.pem"} {
+		tmpFile := filepath.Join(tmpDir, name)
+		if err := extractArchiveFile(file, backupStackPrefix+"certs/"+name, tmpFile); err != nil {
+			return err
+		}
+
+		dst := filepath.Join(caRoot, name)
+
+		isSame, err := deepCompare(tmpFile, dst)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if isSame {
+			continue
+		}
+
+		if err == nil && !changed {
+			fmt.Printf("WARN: local CA in %s differs from backup\n", caRoot)
+			if !Confirm("Replace local CA with the one from backup?", false) {
+				return fmt.Errorf("decided to keep local CA")
+			}
+		}
+
+		if err := os.MkdirAll(caRoot, 0o755); err != nil {
+			return err
+		}
+		if err := os.Remove(dst); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if err := copyFile(tmpFile, dst); err != nil {
+			return err
+		}
+
+		changed = true
+	}
+
+	if changed {
+		fmt.Printf("INFO: local CA restored to %s, please run 'mkcert -install' to trust it.\n", caRoot)
+	}
+
+	return nil
+}
+
+// restoreVolume replaces volume content with backup entries.
+func restoreVolume(file, volume string) error {
+	prefix := backupVolumesPrefix + volume + "/"
+
+	pr, pw := io.Pipe()
+
+	go func() {
+		tw := tar.NewWriter(pw)
+
+		err := walkArchive(file, func(hdr *tar.Header, r io.Reader) error {
+			if !strings.HasPrefix(hdr.Name, prefix) {
+				return nil
+			}
+
+			hdr.Name = "./" + strings.TrimPrefix(hdr.Name, prefix)
+			if hdr.Typeflag == tar.TypeLink {
+				hdr.Linkname = "./" + strings.TrimPrefix(hdr.Linkname, prefix)
+			}
+
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			_, err := io.Copy(tw, r)
+			return err
+		})
+		if err == nil {
+			err = tw.Close()
+		}
+
+		pw.CloseWithError(err)
+	}()
+
+	err := importVolume(volume, pr)
+	pr.Close()
+
+	return err
+}
+
+func restoreBackup(file string) error {
+	fmt.Printf("Verifying backup %q ...\n", file)
+
+	sv, volumes, err := verifyBackup(file)
+	if err != nil {
+		return err
+	}
+
+	if err := checkBackupCompatibility(sv); err != nil {
+		return err
+	}
+
+	fmt.Printf("Backup of stack created at %s, updated at %s\n", sv.CreatedAt.Format(time.RFC3339), sv.UpdatedAt.Format(time.RFC3339))
+
+	for _, service := range []string{"traefik", "dnsmasq", "portainer"} {
+		if stackServiceRunning(service) {
+			return fmt.Errorf("stack is running, please run 'down' command first")
+		}
+	}
+
+	if !Confirm("Restore will overwrite local CA, settings and stack volumes. Continue?", false) {
+		return fmt.Errorf("decided to postpone restore")
+	}
+
+	if err := restoreLocalCA(file); err != nil {
+		return err
+	}
+
+	settingsName := backupConfigPrefix + filepath.Base(settingsPath())
+	if err := extractArchiveFile(file, settingsName, settingsPath()); err != nil && !errors.Is(err, ErrArchiveInvalid) {
+		return err
+	}
+
+	dest := stackDir()
+	if err := os.MkdirAll(dest, 0o755); err != nil {
+		return err
+	}
+	if err := extractArchiveFile(file, backupStackPrefix+stackInfoFile, filepath.Join(dest, stackInfoFile)); err != nil {
+		return err
+	}
+
+	if _, err := syncStack(true); err != nil {
+		return err
+	}
+
+	if len(volumes) > 0 {
+		if err := ensureStackImage("mkcert"); err != nil {
+			return err
+		}
+	}
+
+	for _, volume := range volumes {
+		fmt.Printf("Restoring volume %q ...\n", volume)
+
+		if err := restoreVolume(file, volume); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+var cmdBackup = &cobra.Command{
+	Use:   "backup <file>",
+	Short: "Archive stack CA, settings and volumes into a checksummed tarball",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		portainer, _ := cmd.Flags().GetBool("portainer")
+
+		if err := createBackup(args[0], portainer); err != nil {
+			return err
+		}
+
+		sum, err := computeSHA256(args[0])
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Backup saved to %s (sha256: %s)\n", args[0], sum)
+
+		return nil
+	},
+}
+
+var cmdRestore = &cobra.Command{
+	Use:   "restore <file>",
+	Short: "Restore stack CA, settings and volumes from backup",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sum, err := computeSHA256(args[0])
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Backup %s (sha256: %s)\n", args[0], sum)
+
+		lock, err := lockStack(stackDir(), stackLockTimeout)
+		if err != nil {
+			return err
+		}
+		defer lock.Unlock()
+
+		if err := restoreBackup(args[0]); err != nil {
+			return err
+		}
+
+		fmt.Printf("Restore DONE\n")
+		fmt.Printf("\nINFO: please run 'up' command.\n")
+
+		return nil
+	},
+}
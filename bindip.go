@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+)
+
+// detectBridgeGateway asks docker for IPv4 gateway of the default bridge network.
+func detectBridgeGateway() (string, error) {
+	out, err := exec.Command("docker", "network", "inspect", "bridge", "--format", "{{json .IPAM.Config}}").Output()
+	if err != nil {
+		return "", fmt.Errorf("docker network inspect failed: %w", err)
+	}
+
+	var configs []struct {
+		Subnet  string
+		Gateway string
+	}
+
+	if err := json.Unmarshal(out, &configs); err != nil {
+		return "", fmt.Errorf("unexpected docker network inspect output: %w", err)
+	}
+
+	for _, c := range configs {
+		if ip := net.ParseIP(c.Gateway); ip != nil && ip.To4() != nil {
+			return c.Gateway, nil
+		}
+	}
+
+	return "", fmt.Errorf("no IPv4 gateway found for bridge network")
+}
+
+// checkBindIP warns if configured DOCKER_DEFAULT_IP differs from detected
+// bridge gateway and offers to persist detected one, if run interactively.
+func checkBindIP() error {
+	detected, err := detectBridgeGateway()
+	if err != nil {
+		fmt.Printf("WARN: unable to detect docker bridge IP (%v)\n", err)
+		return nil
+	}
+
+	configured, source := settingValue("DOCKER_DEFAULT_IP")
+	if configured == detected {
+		return nil
+	}
+
+	fmt.Printf("WARN: DOCKER_DEFAULT_IP is %s (%s), but docker bridge gateway is %s\n", configured, source, detected)
+
+	if source == "environment" {
+		fmt.Printf("INFO: DOCKER_DEFAULT_IP is set in environment, please adjust it manually.\n")
+		return nil
+	}
+
+	// bridge gateway is not host bind IP on every setup (eg. Colima, Docker Desktop),
+	// so never persist it without explicit consent
+	if !isTerminal(os.Stdin) {
+		fmt.Printf("WARN: stdin is not a terminal, keeping %s; persist detected one using 'settings set DOCKER_DEFAULT_IP=%s' command.\n", configured, detected)
+		return nil
+	}
+
+	if !Confirm(fmt.Sprintf("Persist detected %s to settings?", detected), false) {
+		fmt.Printf("INFO: decided to keep %s\n", configured)
+		return nil
+	}
+
+	if err := setSetting("DOCKER_DEFAULT_IP", detected); err != nil {
+		return err
+	}
+
+	fmt.Printf("Settings saved to %s\n", settingsPath())
+
+	return nil
+}
+
+func renderBindIP() string {
+	ip, source := settingValue("DOCKER_DEFAULT_IP")
+
+	info := fmt.Sprintf("%s (from %s", ip, source)
+
+	if detected, err := detectBridgeGateway(); err != nil {
+		info += ", detection failed"
+	} else if detected == ip {
+		info += ", matches docker bridge"
+	} else {
+		info += fmt.Sprintf(", docker bridge is %s", detected)
+	}
+
+	return info + ")"
+}
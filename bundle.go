@@ -0,0 +1,282 @@
+package main
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// bundle archive layout
+const (
+	bundleMetaFile    = "bundle.json"
+	bundleImagesFile  = "images.tar"
+	bundleStackPrefix = "stack/"
+)
+
+type BundleMeta struct {
+	Binary    BinaryVersion `json:"binary"`
+	CreatedAt time.Time     `json:"created_at"`
+	Images    []string      `json:"images"`
+}
+
+// stackImages lists images used by the stack, including ones built locally.
+func stackImages() ([]string, error) {
+	out, err := dockerComposeOutput("config", "--images")
+	if err != nil {
+		return nil, fmt.Errorf("docker compose config failed: %w", err)
+	}
+
+	var images []string
+	seen := map[string]bool{}
+
+	for _, line := range strings.Split(string(out), "\n") {
+		image := strings.TrimSpace(line)
+		if image == "" || seen[image] {
+			continue
+		}
+		seen[image] = true
+		images = append(images, image)
+	}
+
+	return images, nil
+}
+
+func exportBundle(file string) error {
+	// images must be built from the very same stack files, which are archived
+	if _, err := syncStack(true); err != nil {
+		return err
+	}
+
+	if !HasInternetConnection() {
+		return fmt.Errorf("running in OFFLINE mode, bundle export requires internet connection")
+	}
+
+	if err := runDockerCompose("build"); err != nil {
+		return err
+	}
+
+	if err := runDockerCompose("pull", "--ignore-buildable"); err != nil {
+		return err
+	}
+
+	images, err := stackImages()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".images-*.tar")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	fmt.Printf("Saving %d images: %s\n", len(images), strings.Join(images, " "))
+
+	cmd := exec.Command("docker", append([]string{"save", "-o", tmp.Name()}, images...)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("docker save failed: %w", err)
+	}
+
+	meta, err := json.MarshalIndent(BundleMeta{
+		Binary: BinaryVersion{
+			Version: buildVersion,
+			Commit:  buildCommit,
+			Date:    buildDate,
+		},
+		CreatedAt: time.Now().Local(),
+		Images:    images,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return createArchive(file, func(w *archiveWriter) error {
+		if err := w.addBytes(bundleMetaFile, 0o644, meta); err != nil {
+			return err
+		}
+
+		for _, f := range stackFilesMeta {
+			data, err := stackFilesFS.ReadFile(f.Path)
+			if err != nil {
+				return err
+			}
+			if err := w.addBytes(bundleStackPrefix+f.Path, int64(f.Perm), data); err != nil {
+				return err
+			}
+		}
+
+		return w.addFile(bundleImagesFile, tmp.Name())
+	})
+}
+
+// verifyBundle validates archive checksums and ensures bundled stack files
+// match the ones embedded into this binary, so loaded images fit the stack.
+func verifyBundle(file string) (*BundleMeta, error) {
+	var meta *BundleMeta
+
+	embedded := map[string]string{}
+	for _, f := range stackFilesMeta {
+		embedded[bundleStackPrefix+f.Path] = f.Sha256
+	}
+
+	bundled := map[string]bool{}
+	mismatches := 0
+
+	err := verifyArchive(file, func(hdr *tar.Header, r io.Reader) error {
+		switch {
+		case hdr.Name == bundleMetaFile:
+			meta = &BundleMeta{}
+			if err := json.NewDecoder(r).Decode(meta); err != nil {
+				return fmt.Errorf("%w: %s: %w", ErrArchiveInvalid, bundleMetaFile, err)
+			}
+		case hdr.Name == bundleImagesFile:
+		case strings.HasPrefix(hdr.Name, bundleStackPrefix):
+			bundled[hdr.Name] = true
+
+			h := sha256.New()
+			if _, err := io.Copy(h, r); err != nil {
+				return fmt.Errorf("%w: %w", ErrArchiveInvalid, err)
+			}
+
+			if want, ok := embedded[hdr.Name]; !ok || want != hex.EncodeToString(h.Sum(nil)) {
+				fmt.Printf("%s: DIFFERS\n", strings.TrimPrefix(hdr.Name, bundleStackPrefix))
+				mismatches++
+			}
+		default:
+			return fmt.Errorf("%w: unexpected entry %q", ErrArchiveInvalid, hdr.Name)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if meta == nil {
+		return nil, fmt.Errorf("%w: %s is missing", ErrArchiveInvalid, bundleMetaFile)
+	}
+
+	for name := range embedded {
+		if !bundled[name] {
+			fmt.Printf("%s: MISSING\n", strings.TrimPrefix(name, bundleStackPrefix))
+			mismatches++
+		}
+	}
+
+	if mismatches > 0 {
+		return nil, fmt.Errorf("bundle was exported by %s %s, its stack differs from %s, please use matching binary",
+			appName, meta.Binary.Version, buildVersion)
+	}
+
+	return meta, nil
+}
+
+func importBundle(file string) error {
+	fmt.Printf("Verifying bundle %q ...\n", file)
+
+	meta, err := verifyBundle(file)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Bundle exported by %s %s at %s\n", appName, meta.Binary.Version, meta.CreatedAt.Format(time.RFC3339))
+
+	tmpDir, err := os.MkdirTemp(filepath.Dir(file), ".bundle-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	imagesFile := filepath.Join(tmpDir, bundleImagesFile)
+	if err := extractArchiveFile(file, bundleImagesFile, imagesFile); err != nil {
+		return err
+	}
+
+	fmt.Printf("Loading %d images ...\n", len(meta.Images))
+
+	cmd := exec.Command("docker", "load", "-i", imagesFile)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("docker load failed: %w", err)
+	}
+
+	if _, err := syncStack(true); err != nil {
+		return err
+	}
+
+	// images are built from the very same stack files, nothing to rebuild
+	if err := os.RemoveAll(filepath.Join(stackDir(), stackRebuildFile)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+var cmdBundle = &cobra.Command{
+	Use:   "bundle",
+	Short: "Export or import air-gapped bundle of stack images and files",
+}
+
+var cmdBundleExport = &cobra.Command{
+	Use:   "export <file>",
+	Short: "Build and save all stack images with stack files into archive",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		lock, err := lockStack(stackDir(), stackLockTimeout)
+		if err != nil {
+			return err
+		}
+		defer lock.Unlock()
+
+		if err := exportBundle(args[0]); err != nil {
+			return err
+		}
+
+		sum, err := computeSHA256(args[0])
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Bundle saved to %s (sha256: %s)\n", args[0], sum)
+
+		return nil
+	},
+}
+
+var cmdBundleImport = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Load stack images and files from archive, no internet required",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		lock, err := lockStack(stackDir(), stackLockTimeout)
+		if err != nil {
+			return err
+		}
+		defer lock.Unlock()
+
+		if err := importBundle(args[0]); err != nil {
+			return err
+		}
+
+		fmt.Printf("Import DONE\n")
+		fmt.Printf("\nINFO: please run 'up --pull never' command.\n")
+
+		return nil
+	},
+}
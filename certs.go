@@ -0,0 +1,305 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	certsChecksumsFile = "checksums.sha256"
+	certsCAFile        = "ca-localtest.pem"
+	certsCAMount       = "/certs_ca" // ./certs of stack directory inside mkcert job
+	certsLeafFile      = "localtest.pem"
+)
+
+const defaultCertsRenewDays = 30
+
+var ErrCertsNotGenerated = errors.New("certificates are not generated yet")
+
+// readCertsVolume returns regular files of certs volume by their relative name.
+func readCertsVolume() (map[string][]byte, error) {
+	if !volumeExists(stackCertsVolume) {
+		return nil, fmt.Errorf("volume %q not found, run 'localtest up' first", stackCertsVolume)
+	}
+
+	if err := ensureStackImage("mkcert"); err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{}
+
+	err := exportVolume(stackCertsVolume, func(hdr *tar.Header, r io.Reader) error {
+		if hdr.Typeflag != tar.TypeReg {
+			return nil
+		}
+
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+
+		files[path.Clean(hdr.Name)] = data
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+type certsChecksum struct {
+	Sha256 string
+	Path   string
+}
+
+// parseCertsChecksums reads sha256sum output written by generate_certs.sh.
+func parseCertsChecksums(data []byte) ([]certsChecksum, error) {
+	var sums []certsChecksum
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		sum, name, ok := strings.Cut(line, " ")
+		if !ok || len(sum) != 2*sha256.Size {
+			return nil, fmt.Errorf("malformed %s line: %q", certsChecksumsFile, line)
+		}
+
+		sums = append(sums, certsChecksum{Sha256: sum, Path: strings.TrimLeft(name, " *")})
+	}
+
+	return sums, scanner.Err()
+}
+
+// verifyCerts re-validates checksums stored in certs volume by mkcert job
+// and ensures bundled CA matches the host one, copied into stack directory.
+func verifyCerts(dest string, verbose bool) error {
+	fmt.Printf("Verifying certificates in %q volume ...\n", stackCertsVolume)
+
+	files, err := readCertsVolume()
+	if err != nil {
+		return err
+	}
+
+	data, ok := files[certsChecksumsFile]
+	if !ok {
+		return fmt.Errorf("%s is missing in %q volume, run 'localtest up' first", certsChecksumsFile, stackCertsVolume)
+	}
+
+	sums, err := parseCertsChecksums(data)
+	if err != nil {
+		return err
+	}
+
+	failures := 0
+
+	for _, sum := range sums {
+		var content []byte
+		var found bool
+
+		switch {
+		case strings.HasPrefix(sum.Path, stackCertsMount+"/"):
+			content, found = files[strings.TrimPrefix(sum.Path, stackCertsMount+"/")]
+		case strings.HasPrefix(sum.Path, certsCAMount+"/"):
+			content, err = os.ReadFile(filepath.Join(dest, "certs", strings.TrimPrefix(sum.Path, certsCAMount+"/")))
+			found = err == nil
+		default:
+			// files of mkcert job container, eg. requested_sans
+			if verbose {
+				fmt.Printf("%s: SKIPPED (ephemeral)\n", sum.Path)
+			}
+			continue
+		}
+
+		if !found {
+			fmt.Printf("%s: MISSING\n", sum.Path)
+			failures++
+			continue
+		}
+
+		if fmt.Sprintf("%x", sha256.Sum256(content)) != sum.Sha256 {
+			fmt.Printf("%s: FAILED\n", sum.Path)
+			failures++
+		} else if verbose {
+			fmt.Printf("%s: OK\n", sum.Path)
+		}
+	}
+
+	hostCA, err := os.ReadFile(filepath.Join(dest, "certs", "rootCA.pem"))
+	if err != nil {
+		return fmt.Errorf("host Root CA is missing in stack directory: %w", err)
+	}
+
+	switch volumeCA, ok := files[certsCAFile]; {
+	case !ok:
+		fmt.Printf("%s: MISSING\n", path.Join(stackCertsMount, certsCAFile))
+		failures++
+	case !bytes.Equal(volumeCA, hostCA):
+		fmt.Printf("%s: DIVERGED from host Root CA, run 'localtest up' to regenerate certificates\n", path.Join(stackCertsMount, certsCAFile))
+		failures++
+	case verbose:
+		fmt.Printf("%s: OK (matches host Root CA)\n", path.Join(stackCertsMount, certsCAFile))
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("WARNING: %d certificate check(s) did NOT pass", failures)
+	}
+
+	return nil
+}
+
+// readCertsVolumeFile reads single file of certs volume, without building helper image.
+func readCertsVolumeFile(name string) ([]byte, error) {
+	if !volumeExists(stackCertsVolume) || !imageExists(stackImage("mkcert")) {
+		return nil, ErrCertsNotGenerated
+	}
+
+	data, err := runVolumeHelper(stackImage("mkcert"), stackCertsVolume, "/volume", true, nil,
+		"cat /volume/"+name)
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func parseCertificate(data []byte) (*x509.Certificate, error) {
+	for {
+		var block *pem.Block
+		if block, data = pem.Decode(data); block == nil {
+			return nil, errors.New("no PEM certificate found")
+		}
+		if block.Type == "CERTIFICATE" {
+			return x509.ParseCertificate(block.Bytes)
+		}
+	}
+}
+
+func leafCertificate() (*x509.Certificate, error) {
+	data, err := readCertsVolumeFile(certsLeafFile)
+	if err != nil {
+		return nil, err
+	}
+	return parseCertificate(data)
+}
+
+func rootCACertificate(dest string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(filepath.Join(dest, "certs", "rootCA.pem"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrCertsNotGenerated
+		}
+		return nil, err
+	}
+	return parseCertificate(data)
+}
+
+func daysRemaining(cert *x509.Certificate) int {
+	return int(time.Until(cert.NotAfter).Hours() / 24)
+}
+
+func certsRenewDays() int {
+	value, _ := settingValue("CERTS_RENEW_DAYS")
+	if days, err := strconv.Atoi(value); err == nil && days > 0 {
+		return days
+	}
+	return defaultCertsRenewDays
+}
+
+func renderExpiry(cert *x509.Certificate, err error) string {
+	if errors.Is(err, ErrCertsNotGenerated) {
+		return "not generated"
+	}
+	if err != nil {
+		return "unknown (" + err.Error() + ")"
+	}
+
+	days := daysRemaining(cert)
+	if days < 0 {
+		return fmt.Sprintf("%s (EXPIRED %d days ago)", cert.NotAfter.Format(time.RFC3339), -days)
+	}
+	return fmt.Sprintf("%s (%d days remaining)", cert.NotAfter.Format(time.RFC3339), days)
+}
+
+// renderDaysLeft renders short expiry summary, as shown by 'info' command.
+func renderDaysLeft(cert *x509.Certificate, err error) string {
+	if err != nil {
+		return renderExpiry(cert, err)
+	}
+
+	days := daysRemaining(cert)
+	if days < 0 {
+		return fmt.Sprintf("EXPIRED %d days ago", -days)
+	}
+	return fmt.Sprintf("expires in %d days", days)
+}
+
+func showCertsStatus(dest string) error {
+	leaf, leafErr := leafCertificate()
+	ca, caErr := rootCACertificate(dest)
+
+	if leafErr != nil && !errors.Is(leafErr, ErrCertsNotGenerated) {
+		return leafErr
+	}
+	if caErr != nil && !errors.Is(caErr, ErrCertsNotGenerated) {
+		return caErr
+	}
+
+	fmt.Printf("Leaf certificate:  %s:%s\n", stackCertsVolume, path.Join(stackCertsMount, certsLeafFile))
+	if leaf != nil {
+		fmt.Printf("  Subject:         %s\n", leaf.Subject)
+		fmt.Printf("  SANs:            %s\n", strings.Join(leaf.DNSNames, " "))
+	}
+	fmt.Printf("  Not after:       %s\n", renderExpiry(leaf, leafErr))
+
+	fmt.Printf("Root CA:           %s\n", filepath.Join(dest, "certs", "rootCA.pem"))
+	if ca != nil {
+		fmt.Printf("  Subject:         %s\n", ca.Subject)
+	}
+	fmt.Printf("  Not after:       %s\n", renderExpiry(ca, caErr))
+
+	renewDays := certsRenewDays()
+	fmt.Printf("Renew window:      %d days (CERTS_RENEW_DAYS)\n", renewDays)
+
+	if leaf != nil && daysRemaining(leaf) < renewDays {
+		fmt.Printf("\nINFO: leaf certificate is within renew window, it is regenerated by mkcert job on next 'up' command.\n")
+	}
+	if ca != nil && daysRemaining(ca) < renewDays {
+		fmt.Printf("\nWARN: Root CA expires soon, regenerate it using 'mkcert -uninstall && mkcert -install'.\n")
+	}
+
+	return nil
+}
+
+var cmdCerts = &cobra.Command{
+	Use:   "certs",
+	Short: "Manage stack TLS certificates",
+}
+
+var cmdCertsStatus = &cobra.Command{
+	Use:   "status",
+	Short: "Show expiry of leaf certificate and Root CA",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return showCertsStatus(stackDir())
+	},
+}
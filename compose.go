@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// generated on each docker compose call from effective settings
+const stackOverrideFile = "compose.settings.yaml"
+
+func renderComposeOverride() (string, error) {
+	var b strings.Builder
+
+	entrypoints, err := effectiveTCPEntrypoints()
+	if err != nil {
+		return "", err
+	}
+
+	ipv6, _ := settingValue("DOCKER_DEFAULT_IPV6")
+	subnet, _ := settingValue("IPV6_SUBNET")
+
+	if ipv6 != "" {
+		if err := validateSetting("DOCKER_DEFAULT_IPV6", ipv6); err != nil {
+			return "", err
+		}
+		if err := validateSetting("IPV6_SUBNET", subnet); err != nil {
+			return "", err
+		}
+	}
+
+	bindIPs := []string{"${DOCKER_DEFAULT_IP:-172.17.0.1}"}
+
+	if ipv6 != "" {
+		bindIPs = append(bindIPs, "["+ipv6+"]")
+
+		b.WriteString("networks:\n")
+		b.WriteString("  localtest:\n")
+		b.WriteString("    enable_ipv6: true\n")
+		if subnet != "" {
+			b.WriteString("    ipam:\n")
+			b.WriteString("      config:\n")
+			fmt.Fprintf(&b, "        - subnet: %s\n", subnet)
+		}
+	}
+
+	if len(entrypoints) == 0 && ipv6 == "" {
+		return "", nil
+	}
+
+	b.WriteString("services:\n")
+
+	if ipv6 != "" {
+		b.WriteString("  dnsmasq:\n")
+		b.WriteString("    ports:\n")
+		fmt.Fprintf(&b, "      - \"[%s]:53:53/udp\"\n", ipv6)
+		fmt.Fprintf(&b, "      - \"[%s]:53:53/tcp\"\n", ipv6)
+		b.WriteString("    command:  # real AAAA answers instead of --filter-AAAA\n")
+		b.WriteString("      - --address=/.test/${DOCKER_DEFAULT_IP:-172.17.0.1}\n")
+		fmt.Fprintf(&b, "      - '--address=/.test/%s'\n", ipv6)
+	}
+
+	b.WriteString("  traefik:\n")
+	b.WriteString("    ports:\n")
+	if ipv6 != "" {
+		fmt.Fprintf(&b, "      - \"[%s]:80:80/tcp\"\n", ipv6)
+		fmt.Fprintf(&b, "      - \"[%s]:443:443/tcp\"\n", ipv6)
+	}
+	for _, ep := range entrypoints {
+		for _, ip := range bindIPs {
+			fmt.Fprintf(&b, "      - \"%s:%d:%d/tcp\"  # %s\n", ip, ep.Port, ep.Port, ep.Name)
+		}
+	}
+
+	return fmt.Sprintf("# Code generated by %s from settings; DO NOT EDIT.\n%s", appName, b.String()), nil
+}
+
+// composeFileArgs returns global docker compose arguments, such as
+// settings env file and compose files to merge, including enabled addons.
+func composeFileArgs(dest string) ([]string, error) {
+	args := []string{}
+
+	if err := migrateStackEnv(dest); err != nil {
+		return nil, fmt.Errorf("failed to migrate .env file: %w", err)
+	}
+
+	if fileExists(settingsPath()) {
+		args = append(args, "--env-file", settingsPath())
+	}
+
+	args = append(args, "-f", filepath.Join(dest, "compose.yaml"))
+
+	addonArgs, err := addonComposeArgs(dest)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, addonArgs...)
+
+	override, err := renderComposeOverride()
+	if err != nil {
+		return nil, err
+	}
+
+	overridePath := filepath.Join(dest, stackOverrideFile)
+
+	if override == "" {
+		if err := os.Remove(overridePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		return args, nil
+	}
+
+	if err := os.WriteFile(overridePath, []byte(override), 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", stackOverrideFile, err)
+	}
+
+	return append(args, "-f", overridePath), nil
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const dnsVolume = "localtest_dns"
+const dnsConfDir = "/etc/dnsmasq.d"
+const dnsRecordsFile = "records.conf"
+
+var dnsNameRe = regexp.MustCompile(`^(?i)[a-z0-9_]([a-z0-9_-]*[a-z0-9])?(\.[a-z0-9_]([a-z0-9_-]*[a-z0-9])?)*$`)
+
+var dnsRecordTypes = []string{"A", "AAAA", "CNAME", "TXT", "SRV", "MX"}
+
+type DNSRecord struct {
+	Type  string
+	Name  string
+	Value []string
+}
+
+func (r DNSRecord) String() string {
+	return fmt.Sprintf("%-40s %-6s %s", r.Name, r.Type, strings.Join(r.Value, " "))
+}
+
+func (r DNSRecord) Equal(o DNSRecord) bool {
+	return r.Type == o.Type && r.Name == o.Name && strings.Join(r.Value, " ") == strings.Join(o.Value, " ")
+}
+
+// Directive renders record as dnsmasq config line.
+func (r DNSRecord) Directive() string {
+	switch r.Type {
+	case "A", "AAAA":
+		return fmt.Sprintf("host-record=%s,%s", r.Name, r.Value[0])
+	case "CNAME":
+		return fmt.Sprintf("cname=%s,%s", r.Name, r.Value[0])
+	case "TXT":
+		return fmt.Sprintf("txt-record=%s,%q", r.Name, r.Value[0])
+	case "SRV":
+		return fmt.Sprintf("srv-host=%s,%s", r.Name, strings.Join(r.Value, ","))
+	case "MX":
+		return fmt.Sprintf("mx-host=%s,%s", r.Name, strings.Join(r.Value, ","))
+	}
+	return ""
+}
+
+func isDNSName(name string) bool {
+	return len(name) <= 253 && dnsNameRe.MatchString(name)
+}
+
+func isUint16(value string) bool {
+	n, err := strconv.Atoi(value)
+	return err == nil && n >= 0 && n <= 65535
+}
+
+// NewDNSRecord validates arguments as passed to 'dns add' command.
+func NewDNSRecord(recordType, name string, value []string) (DNSRecord, error) {
+	r := DNSRecord{
+		Type:  strings.ToUpper(recordType),
+		Name:  strings.ToLower(strings.TrimSuffix(name, ".")),
+		Value: value,
+	}
+
+	if !isDNSName(r.Name) {
+		return r, fmt.Errorf("invalid name %q", name)
+	}
+
+	usage := func(format string) error {
+		return fmt.Errorf("invalid %s record, expected: %s NAME %s", r.Type, r.Type, format)
+	}
+
+	switch r.Type {
+	case "A":
+		if len(value) != 1 || net.ParseIP(value[0]) == nil || net.ParseIP(value[0]).To4() == nil {
+			return r, usage("IPV4")
+		}
+	case "AAAA":
+		if len(value) != 1 || net.ParseIP(value[0]) == nil || net.ParseIP(value[0]).To4() != nil {
+			return r, usage("IPV6")
+		}
+	case "CNAME":
+		if len(value) != 1 || !isDNSName(strings.TrimSuffix(value[0], ".")) {
+			return r, usage("TARGET")
+		}
+		r.Value = []string{strings.ToLower(strings.TrimSuffix(value[0], "."))}
+	case "TXT":
+		text := strings.Join(value, " ")
+		if len(value) == 0 || strings.ContainsAny(text, "\"\\\n") {
+			return r, usage("TEXT (without quotes)")
+		}
+		r.Value = []string{text}
+	case "SRV":
+		// TARGET PORT [PRIORITY [WEIGHT]]
+		if len(value) < 2 || len(value) > 4 || !isDNSName(value[0]) {
+			return r, usage("TARGET PORT [PRIORITY [WEIGHT]]")
+		}
+		for _, n := range value[1:] {
+			if !isUint16(n) {
+				return r, usage("TARGET PORT [PRIORITY [WEIGHT]]")
+			}
+		}
+		for len(r.Value) < 4 {
+			r.Value = append(r.Value, "0")
+		}
+	case "MX":
+		// TARGET [PREFERENCE]
+		if len(value) < 1 || len(value) > 2 || !isDNSName(value[0]) {
+			return r, usage("TARGET [PREFERENCE]")
+		}
+		if len(value) == 2 && !isUint16(value[1]) {
+			return r, usage("TARGET [PREFERENCE]")
+		}
+		if len(r.Value) == 1 {
+			r.Value = append(r.Value, "10")
+		}
+	default:
+		return r, fmt.Errorf("unsupported record type %q, expected one of: %s", recordType, strings.Join(dnsRecordTypes, ", "))
+	}
+
+	return r, nil
+}
+
+// parseDNSRecords reads back records previously rendered by Directive.
+func parseDNSRecords(data []byte) ([]DNSRecord, error) {
+	var records []DNSRecord
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, _ := strings.Cut(line, "=")
+		fields := strings.Split(value, ",")
+
+		r := DNSRecord{Name: fields[0], Value: fields[1:]}
+
+		switch key {
+		case "host-record":
+			r.Type = "A"
+			if ip := net.ParseIP(fields[len(fields)-1]); ip != nil && ip.To4() == nil {
+				r.Type = "AAAA"
+			}
+		case "cname":
+			r.Type = "CNAME"
+		case "txt-record":
+			r.Type = "TXT"
+			text, err := strconv.Unquote(strings.Join(fields[1:], ","))
+			if err != nil {
+				return nil, fmt.Errorf("invalid line %q: %w", line, err)
+			}
+			r.Value = []string{text}
+		case "srv-host":
+			r.Type = "SRV"
+		case "mx-host":
+			r.Type = "MX"
+		default:
+			return nil, fmt.Errorf("unexpected line %q", line)
+		}
+
+		records = append(records, r)
+	}
+
+	return records, scanner.Err()
+}
+
+func renderDNSRecords(records []DNSRecord) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "# Managed by '%s dns' command; DO NOT EDIT.\n", appName)
+	for _, r := range records {
+		fmt.Fprintln(&b, r.Directive())
+	}
+
+	return b.Bytes()
+}
+
+func loadDNSRecords() ([]DNSRecord, error) {
+	if err := ensureStackImage("dnsmasq"); err != nil {
+		return nil, err
+	}
+
+	script := fmt.Sprintf(`cat %s/%s 2>/dev/null || :`, dnsConfDir, dnsRecordsFile)
+
+	out, err := runVolumeHelper(stackImage("dnsmasq"), dnsVolume, dnsConfDir, true, nil, script)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseDNSRecords(out)
+}
+
+// saveDNSRecords replaces records fragment, validates dnsmasq config
+// (restoring previous fragment on failure) and reloads running dnsmasq.
+func saveDNSRecords(records []DNSRecord) error {
+	if err := ensureStackImage("dnsmasq"); err != nil {
+		return err
+	}
+
+	script := fmt.Sprintf(`
+cd %[1]s
+cat > %[2]s.new
+[ ! -f %[2]s ] || cp %[2]s %[2]s.bak
+mv %[2]s.new %[2]s
+if ! dnsmasq --test; then
+	if [ -f %[2]s.bak ]; then mv %[2]s.bak %[2]s; else rm -f %[2]s; fi
+	exit 1
+fi
+rm -f %[2]s.bak
+`, dnsConfDir, dnsRecordsFile)
+
+	// volume is mounted as conf-dir, so dnsmasq validates final config
+	if _, err := runVolumeHelper(stackImage("dnsmasq"), dnsVolume, dnsConfDir, false, renderDNSRecords(records), script); err != nil {
+		return fmt.Errorf("failed to save DNS records: %w", err)
+	}
+
+	if !stackServiceRunning("dnsmasq") {
+		fmt.Printf("INFO: dnsmasq is not running, records will be applied on 'up' command.\n")
+		return nil
+	}
+
+	if _, err := dockerComposeOutput("kill", "-s", "HUP", "dnsmasq"); err != nil {
+		return fmt.Errorf("failed to reload dnsmasq: %w", err)
+	}
+
+	fmt.Printf("dnsmasq reloaded\n")
+
+	return nil
+}
+
+var cmdDNS = &cobra.Command{
+	Use:   "dns",
+	Short: "Manage custom DNS records of the stack nameserver",
+	Long: `Manage custom DNS records of the stack nameserver.
+
+Records take precedence over wildcard *.test address of the stack.
+NOTE: CNAME target must be resolved by the stack nameserver itself,
+so point it to A/AAAA record managed here.`,
+}
+
+var cmdDNSAdd = &cobra.Command{
+	Use:   "add TYPE NAME VALUE...",
+	Short: "Add DNS record (" + strings.Join(dnsRecordTypes, ", ") + ")",
+	Example: fmt.Sprintf(`  %[1]s dns add A legacy.app.test 192.168.1.10
+  %[1]s dns add CNAME api.app.test legacy.app.test
+  %[1]s dns add TXT app.test some text
+  %[1]s dns add SRV _http._tcp.app.test legacy.app.test 443 10 5
+  %[1]s dns add MX app.test mail.app.test 10`, appName),
+	Args: cobra.MinimumNArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		record, err := NewDNSRecord(args[0], args[1], args[2:])
+		if err != nil {
+			return err
+		}
+
+		records, err := loadDNSRecords()
+		if err != nil {
+			return err
+		}
+
+		for _, r := range records {
+			if r.Equal(record) {
+				return fmt.Errorf("record already exists: %s", record)
+			}
+		}
+
+		if err := saveDNSRecords(append(records, record)); err != nil {
+			return err
+		}
+
+		fmt.Printf("Added: %s\n", record)
+
+		if ipv6, _ := settingValue("DOCKER_DEFAULT_IPV6"); record.Type == "AAAA" && ipv6 == "" {
+			fmt.Printf("WARN: AAAA answers are filtered unless DOCKER_DEFAULT_IPV6 setting enables dual-stack mode\n")
+		}
+
+		return nil
+	},
+}
+
+var cmdDNSRm = &cobra.Command{
+	Use:   "rm TYPE NAME [VALUE...]",
+	Short: "Remove DNS records, matching value if given",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		recordType := strings.ToUpper(args[0])
+		name := strings.ToLower(strings.TrimSuffix(args[1], "."))
+
+		var match *DNSRecord
+		if len(args) > 2 {
+			record, err := NewDNSRecord(recordType, name, args[2:])
+			if err != nil {
+				return err
+			}
+			match = &record
+		}
+
+		records, err := loadDNSRecords()
+		if err != nil {
+			return err
+		}
+
+		var kept []DNSRecord
+		for _, r := range records {
+			if r.Type == recordType && r.Name == name && (match == nil || r.Equal(*match)) {
+				fmt.Printf("Removed: %s\n", r)
+				continue
+			}
+			kept = append(kept, r)
+		}
+
+		if len(kept) == len(records) {
+			return fmt.Errorf("no matching %s record for %q", recordType, name)
+		}
+
+		return saveDNSRecords(kept)
+	},
+}
+
+var cmdDNSList = &cobra.Command{
+	Use:   "list",
+	Short: "List DNS records",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		records, err := loadDNSRecords()
+		if err != nil {
+			return err
+		}
+
+		if len(records) == 0 {
+			fmt.Println("No custom DNS records")
+			return nil
+		}
+
+		fmt.Printf("%-40s %-6s %s\n", "NAME", "TYPE", "VALUE")
+		for _, r := range records {
+			fmt.Println(r)
+		}
+
+		return nil
+	},
+}
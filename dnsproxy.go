@@ -0,0 +1,601 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const dnsProxyPidFile = "dns-proxy.pid"
+const dnsProxyLogFile = "dns-proxy.log"
+
+const dnsHeaderSize = 12
+const dnsMaxUDPSize = 4096
+const dnsRcodeNXDomain = 3
+
+var ErrDNSMessageInvalid = errors.New("invalid DNS message")
+
+func runtimeDir() string {
+	// mimic $XDG_RUNTIME_DIR
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, appName)
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("%s-%d", appName, os.Getuid()))
+}
+
+// parseDNSQuestion returns lowercased FQDN and type of the first question,
+// and offset where the question section ends.
+func parseDNSQuestion(msg []byte) (string, uint16, int, error) {
+	if len(msg) < dnsHeaderSize {
+		return "", 0, 0, fmt.Errorf("%w: too short", ErrDNSMessageInvalid)
+	}
+
+	if binary.BigEndian.Uint16(msg[4:6]) < 1 {
+		return "", 0, 0, fmt.Errorf("%w: no question", ErrDNSMessageInvalid)
+	}
+
+	var labels []string
+
+	off := dnsHeaderSize
+	for {
+		if off >= len(msg) {
+			return "", 0, 0, fmt.Errorf("%w: truncated name", ErrDNSMessageInvalid)
+		}
+
+		length := int(msg[off])
+		off++
+
+		if length == 0 {
+			break
+		}
+		// compression pointers are not expected in questions
+		if length&0xC0 != 0 || off+length > len(msg) {
+			return "", 0, 0, fmt.Errorf("%w: bad label", ErrDNSMessageInvalid)
+		}
+
+		labels = append(labels, strings.ToLower(string(msg[off:off+length])))
+		off += length
+	}
+
+	if off+4 > len(msg) {
+		return "", 0, 0, fmt.Errorf("%w: truncated question", ErrDNSMessageInvalid)
+	}
+
+	qtype := binary.BigEndian.Uint16(msg[off : off+2])
+
+	return strings.Join(labels, ".") + ".", qtype, off + 4, nil
+}
+
+// nxdomainResponse answers the query with its first question and NXDOMAIN code.
+func nxdomainResponse(query []byte, questionEnd int) []byte {
+	resp := make([]byte, questionEnd)
+	copy(resp, query[:questionEnd])
+
+	flags := binary.BigEndian.Uint16(query[2:4])
+	flags = 0x8000 | (flags & 0x7900) | 0x0080 | dnsRcodeNXDomain // QR, keep OPCODE & RD, set RA
+
+	binary.BigEndian.PutUint16(resp[2:4], flags)
+	binary.BigEndian.PutUint16(resp[4:6], 1) // QDCOUNT
+	binary.BigEndian.PutUint16(resp[6:8], 0) // ANCOUNT
+	binary.BigEndian.PutUint16(resp[8:10], 0)
+	binary.BigEndian.PutUint16(resp[10:12], 0)
+
+	return resp
+}
+
+const dnsTypeA = 1
+const dnsTypeAAAA = 28
+
+// buildDNSQuery returns recursive query with single question of IN class.
+func buildDNSQuery(id uint16, name string, qtype uint16) []byte {
+	msg := make([]byte, dnsHeaderSize, dnsHeaderSize+len(name)+6)
+	binary.BigEndian.PutUint16(msg[0:2], id)
+	binary.BigEndian.PutUint16(msg[2:4], 0x0100) // RD
+	binary.BigEndian.PutUint16(msg[4:6], 1)
+
+	for _, label := range strings.Split(strings.Trim(name, "."), ".") {
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0)
+
+	return binary.BigEndian.AppendUint16(binary.BigEndian.AppendUint16(msg, qtype), 1)
+}
+
+// skipDNSName returns offset after possibly compressed name.
+func skipDNSName(msg []byte, off int) (int, error) {
+	for {
+		if off >= len(msg) {
+			return 0, fmt.Errorf("%w: truncated name", ErrDNSMessageInvalid)
+		}
+		length := int(msg[off])
+		switch {
+		case length == 0:
+			return off + 1, nil
+		case length&0xC0 == 0xC0:
+			return off + 2, nil
+		}
+		off += 1 + length
+	}
+}
+
+// parseDNSAnswerIPs returns rcode and A/AAAA addresses from the answer section.
+func parseDNSAnswerIPs(msg []byte) (int, []net.IP, error) {
+	_, _, off, err := parseDNSQuestion(msg)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	rcode := int(msg[3] & 0x0F)
+	count := int(binary.BigEndian.Uint16(msg[6:8]))
+
+	var ips []net.IP
+	for i := 0; i < count; i++ {
+		if off, err = skipDNSName(msg, off); err != nil {
+			return rcode, nil, err
+		}
+		if off+10 > len(msg) {
+			return rcode, nil, fmt.Errorf("%w: truncated answer", ErrDNSMessageInvalid)
+		}
+
+		rtype := binary.BigEndian.Uint16(msg[off : off+2])
+		rdlen := int(binary.BigEndian.Uint16(msg[off+8 : off+10]))
+		off += 10
+
+		if off+rdlen > len(msg) {
+			return rcode, nil, fmt.Errorf("%w: truncated rdata", ErrDNSMessageInvalid)
+		}
+
+		if (rtype == dnsTypeA && rdlen == net.IPv4len) || (rtype == dnsTypeAAAA && rdlen == net.IPv6len) {
+			ips = append(ips, net.IP(append([]byte{}, msg[off:off+rdlen]...)))
+		}
+		off += rdlen
+	}
+
+	return rcode, ips, nil
+}
+
+// queryDNS asks nameserver directly over UDP, bypassing host resolver.
+func queryDNS(server, name string, qtype uint16, timeout time.Duration) ([]net.IP, error) {
+	proxy := DNSProxy{Upstream: server, Timeout: timeout}
+
+	resp, err := proxy.exchange(buildDNSQuery(uint16(time.Now().UnixNano()), name, qtype), "udp")
+	if err != nil {
+		return nil, err
+	}
+
+	rcode, ips, err := parseDNSAnswerIPs(resp)
+	if err != nil {
+		return nil, err
+	}
+	if rcode != 0 {
+		return nil, fmt.Errorf("rcode %d", rcode)
+	}
+
+	return ips, nil
+}
+
+type DNSProxy struct {
+	Listen   string
+	Upstream string
+	TLDs     []string
+	Timeout  time.Duration
+	Verbose  bool
+}
+
+func (p DNSProxy) matchesTLD(name string) bool {
+	for _, tld := range p.TLDs {
+		tld = strings.Trim(strings.ToLower(tld), ".") + "."
+		if name == tld || strings.HasSuffix(name, "."+tld) {
+			return true
+		}
+	}
+	return false
+}
+
+// handle returns response for the query, either forwarded or NXDOMAIN.
+func (p DNSProxy) handle(query []byte, network string) ([]byte, error) {
+	name, qtype, end, err := parseDNSQuestion(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.matchesTLD(name) {
+		if p.Verbose {
+			fmt.Printf("%s query[%d] %s: NXDOMAIN\n", network, qtype, name)
+		}
+		return nxdomainResponse(query, end), nil
+	}
+
+	if p.Verbose {
+		fmt.Printf("%s query[%d] %s: forwarded to %s\n", network, qtype, name, p.Upstream)
+	}
+
+	return p.exchange(query, network)
+}
+
+func (p DNSProxy) exchange(query []byte, network string) ([]byte, error) {
+	conn, err := net.DialTimeout(network, p.Upstream, p.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(p.Timeout)); err != nil {
+		return nil, err
+	}
+
+	if network == "udp" {
+		if _, err := conn.Write(query); err != nil {
+			return nil, err
+		}
+
+		buf := make([]byte, dnsMaxUDPSize)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+
+	if err := writeDNSStream(conn, query); err != nil {
+		return nil, err
+	}
+
+	return readDNSStream(conn)
+}
+
+func writeDNSStream(w io.Writer, msg []byte) error {
+	buf := make([]byte, 2+len(msg))
+	binary.BigEndian.PutUint16(buf, uint16(len(msg)))
+	copy(buf[2:], msg)
+	_, err := w.Write(buf)
+	return err
+}
+
+func readDNSStream(r io.Reader) ([]byte, error) {
+	var length uint16
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, length)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (p DNSProxy) serveUDP(ctx context.Context, conn net.PacketConn) error {
+	for {
+		buf := make([]byte, dnsMaxUDPSize)
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		go func(query []byte, addr net.Addr) {
+			resp, err := p.handle(query, "udp")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "udp %s: %v\n", addr, err)
+				return
+			}
+			conn.WriteTo(resp, addr)
+		}(buf[:n], addr)
+	}
+}
+
+func (p DNSProxy) serveTCP(ctx context.Context, ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		go func(conn net.Conn) {
+			defer conn.Close()
+
+			for {
+				conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+				query, err := readDNSStream(conn)
+				if err != nil {
+					return
+				}
+
+				resp, err := p.handle(query, "tcp")
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "tcp %s: %v\n", conn.RemoteAddr(), err)
+					return
+				}
+
+				if err := writeDNSStream(conn, resp); err != nil {
+					return
+				}
+			}
+		}(conn)
+	}
+}
+
+// Serve listens on both UDP and TCP until context is cancelled.
+func (p DNSProxy) Serve(ctx context.Context) error {
+	udpConn, tcpLn, err := p.listen()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("DNS proxy listening on %s (udp/tcp), forwarding %s to %s\n",
+		udpConn.LocalAddr(), strings.Join(p.TLDs, ", "), p.Upstream)
+
+	return p.serve(ctx, udpConn, tcpLn)
+}
+
+// listen binds UDP first, then TCP on the same address, so that
+// port 0 resolves to the same port for both.
+func (p DNSProxy) listen() (net.PacketConn, net.Listener, error) {
+	udpConn, err := net.ListenPacket("udp", p.Listen)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tcpLn, err := net.Listen("tcp", udpConn.LocalAddr().String())
+	if err != nil {
+		udpConn.Close()
+		return nil, nil, err
+	}
+
+	return udpConn, tcpLn, nil
+}
+
+// serve runs both loops until context is cancelled or either of them fails,
+// closing both listeners.
+func (p DNSProxy) serve(ctx context.Context, udpConn net.PacketConn, tcpLn net.Listener) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+
+	wg.Add(2)
+	go func() { defer wg.Done(); errs <- p.serveUDP(ctx, udpConn) }()
+	go func() { defer wg.Done(); errs <- p.serveTCP(ctx, tcpLn) }()
+
+	var err error
+	select {
+	case err = <-errs:
+	case <-ctx.Done():
+	}
+
+	// unblock the other loop, it returns nil once context is cancelled
+	cancel()
+	udpConn.Close()
+	tcpLn.Close()
+
+	wg.Wait()
+
+	return err
+}
+
+func readPidFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+func dnsProxyFromFlags(cmd *cobra.Command) (DNSProxy, error) {
+	listen, _ := cmd.Flags().GetString("listen")
+	upstream, _ := cmd.Flags().GetString("upstream")
+	tlds, _ := cmd.Flags().GetStringSlice("tld")
+	verbose, _ := cmd.Flags().GetBool("verbose")
+
+	if upstream == "" {
+		ip, _ := settingValue("DOCKER_DEFAULT_IP")
+		upstream = net.JoinHostPort(ip, "53")
+	}
+
+	if len(tlds) == 0 {
+		return DNSProxy{}, fmt.Errorf("at least one --tld is required")
+	}
+
+	return DNSProxy{
+		Listen:   listen,
+		Upstream: upstream,
+		TLDs:     tlds,
+		Timeout:  2 * time.Second,
+		Verbose:  verbose,
+	}, nil
+}
+
+func dnsProxyPidPath(cmd *cobra.Command) string {
+	if path, _ := cmd.Flags().GetString("pidfile"); path != "" {
+		return path
+	}
+	return filepath.Join(runtimeDir(), dnsProxyPidFile)
+}
+
+var cmdDNSProxy = &cobra.Command{
+	Use:   "dns-proxy",
+	Short: "Forward DNS queries for stack TLDs to the stack nameserver",
+	Long: fmt.Sprintf(`Forward DNS queries for stack TLDs to the stack nameserver.
+
+Replacement for a host DNS forwarder (eg. dnsmasq on macOS), queries for
+other domains are answered with NXDOMAIN.
+
+Point host resolver to it, eg. on macOS:
+  $ echo 'nameserver 127.0.0.1' | sudo tee /etc/resolver/test
+  $ sudo %s dns-proxy start`, appName),
+}
+
+var cmdDNSProxyRun = &cobra.Command{
+	Use:   "run",
+	Short: "Run DNS proxy in foreground",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		proxy, err := dnsProxyFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		pidFile, _ := cmd.Flags().GetString("pidfile")
+		if pidFile != "" {
+			if err := os.MkdirAll(filepath.Dir(pidFile), 0700); err != nil {
+				return err
+			}
+			if err := os.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0o644); err != nil {
+				return err
+			}
+			defer os.Remove(pidFile)
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		return proxy.Serve(ctx)
+	},
+}
+
+var cmdDNSProxyStart = &cobra.Command{
+	Use:   "start",
+	Short: "Start DNS proxy in background",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		proxy, err := dnsProxyFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		pidFile := dnsProxyPidPath(cmd)
+
+		if pid, err := readPidFile(pidFile); err == nil && processAlive(pid) {
+			return fmt.Errorf("DNS proxy is already running with PID %d", pid)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(pidFile), 0700); err != nil {
+			return err
+		}
+
+		logFile := filepath.Join(filepath.Dir(pidFile), dnsProxyLogFile)
+		logOut, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		defer logOut.Close()
+
+		self, err := os.Executable()
+		if err != nil {
+			return err
+		}
+
+		runArgs := []string{"dns-proxy", "run",
+			"--listen", proxy.Listen,
+			"--upstream", proxy.Upstream,
+			"--tld", strings.Join(proxy.TLDs, ","),
+			"--pidfile", pidFile,
+		}
+		if proxy.Verbose {
+			runArgs = append(runArgs, "--verbose")
+		}
+
+		child := exec.Command(self, runArgs...)
+		child.Stdout = logOut
+		child.Stderr = logOut
+		child.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+
+		if err := child.Start(); err != nil {
+			return err
+		}
+
+		exited := make(chan error, 1)
+		go func() { exited <- child.Wait() }()
+
+		// give it a moment to bind ports
+		select {
+		case err := <-exited:
+			return fmt.Errorf("DNS proxy exited (%v), check %s", err, logFile)
+		case <-time.After(500 * time.Millisecond):
+		}
+
+		fmt.Printf("DNS proxy started with PID %d, logs in %s\n", child.Process.Pid, logFile)
+
+		return nil
+	},
+}
+
+var cmdDNSProxyStop = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop background DNS proxy",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pidFile := dnsProxyPidPath(cmd)
+
+		pid, err := readPidFile(pidFile)
+		if err != nil || !processAlive(pid) {
+			os.Remove(pidFile)
+			fmt.Println("DNS proxy is not running")
+			return nil
+		}
+
+		if err := syscall.Kill(pid, syscall.SIGTERM); err != nil {
+			return fmt.Errorf("failed to stop PID %d: %w", pid, err)
+		}
+
+		for i := 0; i < 50 && processAlive(pid); i++ {
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		if processAlive(pid) {
+			return fmt.Errorf("DNS proxy with PID %d did not stop in time", pid)
+		}
+
+		fmt.Printf("DNS proxy with PID %d stopped\n", pid)
+
+		return nil
+	},
+}
+
+var cmdDNSProxyStatus = &cobra.Command{
+	Use:   "status",
+	Short: "Show background DNS proxy status",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pidFile := dnsProxyPidPath(cmd)
+
+		pid, err := readPidFile(pidFile)
+		if err != nil || !processAlive(pid) {
+			return fmt.Errorf("DNS proxy is not running")
+		}
+
+		fmt.Printf("DNS proxy is running with PID %d (%s)\n", pid, pidFile)
+
+		return nil
+	},
+}
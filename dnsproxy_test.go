@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeUpstream answers every query with A record 10.0.0.1 over UDP and TCP,
+// counting queries it has received.
+type fakeUpstream struct {
+	addr    string
+	queries atomic.Int32
+}
+
+func answerA(query []byte) []byte {
+	_, _, end, err := parseDNSQuestion(query)
+	if err != nil {
+		return nil
+	}
+
+	resp := append([]byte{}, query[:end]...)
+	binary.BigEndian.PutUint16(resp[2:4], 0x8180) // QR, RD, RA
+	binary.BigEndian.PutUint16(resp[6:8], 1)      // ANCOUNT
+
+	resp = append(resp, 0xC0, dnsHeaderSize) // pointer to question name
+	resp = binary.BigEndian.AppendUint16(resp, dnsTypeA)
+	resp = binary.BigEndian.AppendUint16(resp, 1)  // IN
+	resp = binary.BigEndian.AppendUint32(resp, 60) // TTL
+	resp = binary.BigEndian.AppendUint16(resp, net.IPv4len)
+
+	return append(resp, 10, 0, 0, 1)
+}
+
+func startFakeUpstream(t *testing.T) *fakeUpstream {
+	t.Helper()
+
+	udpConn, tcpLn, err := DNSProxy{Listen: "127.0.0.1:0"}.listen()
+	if err != nil {
+		t.Fatalf("listen upstream: %v", err)
+	}
+	t.Cleanup(func() { udpConn.Close(); tcpLn.Close() })
+
+	up := &fakeUpstream{addr: udpConn.LocalAddr().String()}
+
+	go func() {
+		buf := make([]byte, dnsMaxUDPSize)
+		for {
+			n, addr, err := udpConn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			up.queries.Add(1)
+			udpConn.WriteTo(answerA(buf[:n]), addr)
+		}
+	}()
+
+	go func() {
+		for {
+			conn, err := tcpLn.Accept()
+			if err != nil {
+				return
+			}
+			query, err := readDNSStream(conn)
+			if err == nil {
+				up.queries.Add(1)
+				writeDNSStream(conn, answerA(query))
+			}
+			conn.Close()
+		}
+	}()
+
+	return up
+}
+
+func startDNSProxy(t *testing.T, upstream string) string {
+	t.Helper()
+
+	proxy := DNSProxy{
+		Listen:   "127.0.0.1:0",
+		Upstream: upstream,
+		TLDs:     []string{"test", ".Local."},
+		Timeout:  2 * time.Second,
+	}
+
+	udpConn, tcpLn, err := proxy.listen()
+	if err != nil {
+		t.Fatalf("listen proxy: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	go func() { done <- proxy.serve(ctx, udpConn, tcpLn) }()
+
+	t.Cleanup(func() {
+		cancel()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("serve() error: %v", err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Error("serve() did not return after cancel")
+		}
+	})
+
+	return udpConn.LocalAddr().String()
+}
+
+func TestDNSProxyServe(t *testing.T) {
+	upstream := startFakeUpstream(t)
+	addr := startDNSProxy(t, upstream.addr)
+
+	client := DNSProxy{Upstream: addr, Timeout: 2 * time.Second}
+
+	tests := []struct {
+		name      string
+		query     string
+		rcode     int
+		ips       []string
+		forwarded bool
+	}{
+		{name: "stack tld", query: "app.local.test", ips: []string{"10.0.0.1"}, forwarded: true},
+		{name: "tld itself", query: "test", ips: []string{"10.0.0.1"}, forwarded: true},
+		{name: "case insensitive", query: "App.My.LOCAL", ips: []string{"10.0.0.1"}, forwarded: true},
+		{name: "other domain", query: "example.com", rcode: dnsRcodeNXDomain},
+		{name: "tld as label", query: "test.example.com", rcode: dnsRcodeNXDomain},
+		{name: "tld as suffix", query: "contest", rcode: dnsRcodeNXDomain},
+	}
+
+	for _, network := range []string{"udp", "tcp"} {
+		for _, tt := range tests {
+			t.Run(network+"/"+tt.name, func(t *testing.T) {
+				before := upstream.queries.Load()
+
+				query := buildDNSQuery(0x1234, tt.query, dnsTypeA)
+
+				resp, err := client.exchange(query, network)
+				if err != nil {
+					t.Fatalf("exchange() error: %v", err)
+				}
+
+				if id := binary.BigEndian.Uint16(resp[0:2]); id != 0x1234 {
+					t.Errorf("response id = %#x, want %#x", id, 0x1234)
+				}
+				if resp[2]&0x80 == 0 {
+					t.Errorf("response QR flag is not set")
+				}
+
+				rcode, ips, err := parseDNSAnswerIPs(resp)
+				if err != nil {
+					t.Fatalf("parseDNSAnswerIPs() error: %v", err)
+				}
+				if rcode != tt.rcode {
+					t.Errorf("rcode = %d, want %d", rcode, tt.rcode)
+				}
+
+				var got []string
+				for _, ip := range ips {
+					got = append(got, ip.String())
+				}
+				if len(got) != len(tt.ips) || (len(got) > 0 && got[0] != tt.ips[0]) {
+					t.Errorf("ips = %v, want %v", got, tt.ips)
+				}
+
+				if forwarded := upstream.queries.Load() > before; forwarded != tt.forwarded {
+					t.Errorf("forwarded = %v, want %v", forwarded, tt.forwarded)
+				}
+			})
+		}
+	}
+}
+
+func TestDNSProxyServeTCPPipelined(t *testing.T) {
+	upstream := startFakeUpstream(t)
+	addr := startDNSProxy(t, upstream.addr)
+
+	conn, err := net.DialTimeout("tcp", addr, 2*time.Second)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	// both queries in one write, answered in order over the same connection
+	var buf []byte
+	for i, name := range []string{"example.com", "app.local.test"} {
+		query := buildDNSQuery(uint16(i+1), name, dnsTypeA)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(query)))
+		buf = append(buf, query...)
+	}
+	if _, err := conn.Write(buf); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	for i, want := range []int{dnsRcodeNXDomain, 0} {
+		resp, err := readDNSStream(conn)
+		if err != nil {
+			t.Fatalf("response %d: %v", i+1, err)
+		}
+		if id := binary.BigEndian.Uint16(resp[0:2]); id != uint16(i+1) {
+			t.Errorf("response %d id = %d", i+1, id)
+		}
+		if rcode := int(resp[3] & 0x0F); rcode != want {
+			t.Errorf("response %d rcode = %d, want %d", i+1, rcode, want)
+		}
+	}
+}
+
+func TestDNSProxyServeStopsOnLoopError(t *testing.T) {
+	proxy := DNSProxy{Listen: "127.0.0.1:0", Timeout: time.Second}
+
+	udpConn, tcpLn, err := proxy.listen()
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- proxy.serve(context.Background(), udpConn, tcpLn) }()
+
+	// fail UDP loop, TCP loop must not keep serve running
+	udpConn.Close()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Errorf("serve() error = %v, want %v", err, net.ErrClosed)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("serve() did not return after UDP loop failed")
+	}
+
+	if conn, err := net.DialTimeout("tcp", tcpLn.Addr().String(), time.Second); err == nil {
+		conn.Close()
+		t.Error("TCP listener is still accepting connections")
+	}
+}
+
+func TestParseDNSQuestion(t *testing.T) {
+	valid := buildDNSQuery(1, "App.Local.Test", dnsTypeAAAA)
+
+	name, qtype, end, err := parseDNSQuestion(valid)
+	if err != nil {
+		t.Fatalf("parseDNSQuestion() error: %v", err)
+	}
+	if name != "app.local.test." || qtype != dnsTypeAAAA || end != len(valid) {
+		t.Errorf("parseDNSQuestion() = %q, %d, %d, want %q, %d, %d", name, qtype, end, "app.local.test.", dnsTypeAAAA, len(valid))
+	}
+
+	noQuestion := append([]byte{}, valid...)
+	binary.BigEndian.PutUint16(noQuestion[4:6], 0)
+
+	pointer := append(append([]byte{}, valid[:dnsHeaderSize]...), 0xC0, dnsHeaderSize, 0, 1, 0, 1)
+
+	tests := []struct {
+		name string
+		msg  []byte
+	}{
+		{name: "too short", msg: valid[:dnsHeaderSize-1]},
+		{name: "no question", msg: noQuestion},
+		{name: "truncated name", msg: valid[:dnsHeaderSize+3]},
+		{name: "truncated question", msg: valid[:len(valid)-2]},
+		{name: "compression pointer", msg: pointer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, _, err := parseDNSQuestion(tt.msg); !errors.Is(err, ErrDNSMessageInvalid) {
+				t.Errorf("parseDNSQuestion() error = %v, want %v", err, ErrDNSMessageInvalid)
+			}
+		})
+	}
+}
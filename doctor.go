@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const doctorProbeName = "local.test"
+const doctorTimeout = 2 * time.Second
+
+type DoctorCheck struct {
+	Name string
+	Run  func() (string, error)
+}
+
+var errSkipped = errors.New("skipped")
+
+func containsIP(ips []net.IP, want net.IP) bool {
+	for _, ip := range ips {
+		if ip.Equal(want) {
+			return true
+		}
+	}
+	return false
+}
+
+func checkDNSAnswer(server, name string, qtype uint16, want net.IP) (string, error) {
+	ips, err := queryDNS(net.JoinHostPort(server, "53"), name, qtype, doctorTimeout)
+	if err != nil {
+		return "", err
+	}
+	if !containsIP(ips, want) {
+		return "", fmt.Errorf("got %v, want %s", ips, want)
+	}
+	return fmt.Sprintf("%s -> %s", name, want), nil
+}
+
+func checkTCPReachable(ip string, port string) (string, error) {
+	addr := net.JoinHostPort(ip, port)
+	conn, err := net.DialTimeout("tcp", addr, doctorTimeout)
+	if err != nil {
+		return "", err
+	}
+	conn.Close()
+	return addr, nil
+}
+
+func checkHostResolver(network string, want net.IP) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+	defer cancel()
+
+	ips, err := net.DefaultResolver.LookupIP(ctx, network, doctorProbeName)
+	if err != nil {
+		return "", err
+	}
+	if !containsIP(ips, want) {
+		return "", fmt.Errorf("got %v, want %s", ips, want)
+	}
+	return fmt.Sprintf("%s -> %s", doctorProbeName, want), nil
+}
+
+func doctorChecks() []DoctorCheck {
+	ipv4Str, _ := settingValue("DOCKER_DEFAULT_IP")
+	ipv6Str, _ := settingValue("DOCKER_DEFAULT_IPV6")
+
+	ipv4 := net.ParseIP(ipv4Str)
+	ipv6 := net.ParseIP(ipv6Str)
+
+	ipv6Check := func(fn func() (string, error)) func() (string, error) {
+		return func() (string, error) {
+			if ipv6 == nil {
+				return "DOCKER_DEFAULT_IPV6 is not set", errSkipped
+			}
+			return fn()
+		}
+	}
+
+	return []DoctorCheck{
+		{"stack synced", func() (string, error) {
+			dest := stackDir()
+			if !fileExists(filepath.Join(dest, stackInfoFile)) {
+				return "", fmt.Errorf("%w in %s", ErrStackNotExist, dest)
+			}
+			return dest, nil
+		}},
+		{"docker server", func() (string, error) {
+			out, err := exec.Command("docker", "version", "--format", "{{.Server.Version}}").Output()
+			if err != nil {
+				return "", err
+			}
+			return strings.TrimSpace(string(out)), nil
+		}},
+		{"dnsmasq running", func() (string, error) {
+			if !stackServiceRunning("dnsmasq") {
+				return "", fmt.Errorf("not running")
+			}
+			return "", nil
+		}},
+		{"traefik running", func() (string, error) {
+			if !stackServiceRunning("traefik") {
+				return "", fmt.Errorf("not running")
+			}
+			return "", nil
+		}},
+		{"IPv4 nameserver", func() (string, error) {
+			return checkDNSAnswer(ipv4Str, doctorProbeName, dnsTypeA, ipv4)
+		}},
+		{"IPv4 router", func() (string, error) {
+			return checkTCPReachable(ipv4Str, "443")
+		}},
+		{"IPv4 host resolver", func() (string, error) {
+			return checkHostResolver("ip4", ipv4)
+		}},
+		{"IPv6 nameserver", ipv6Check(func() (string, error) {
+			return checkDNSAnswer(ipv6Str, doctorProbeName, dnsTypeAAAA, ipv6)
+		})},
+		{"IPv6 router", ipv6Check(func() (string, error) {
+			return checkTCPReachable(ipv6Str, "443")
+		})},
+		{"IPv6 host resolver", ipv6Check(func() (string, error) {
+			return checkHostResolver("ip6", ipv6)
+		})},
+	}
+}
+
+var cmdDoctor = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check stack health and reachability from host",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		failures := 0
+
+		for _, check := range doctorChecks() {
+			detail, err := check.Run()
+
+			switch {
+			case errors.Is(err, errSkipped):
+				fmt.Printf("SKIP  %-20s %s\n", check.Name, detail)
+			case err != nil:
+				fmt.Printf("FAIL  %-20s %v\n", check.Name, err)
+				failures++
+			default:
+				fmt.Printf("OK    %-20s %s\n", check.Name, detail)
+			}
+		}
+
+		if failures > 0 {
+			return fmt.Errorf("%d check(s) failed, see README troubleshooting section", failures)
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+type TCPEntrypoint struct {
+	Name string
+	Port int
+}
+
+var entrypointNameRe = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+var reservedEntrypoints = []string{"http", "https", "traefik"}
+
+// parseTCPEntrypoints parses space separated "name:port" pairs, eg. "postgres:5432 redis:6379"
+func parseTCPEntrypoints(value string) ([]TCPEntrypoint, error) {
+	var entrypoints []TCPEntrypoint
+
+	seenNames := map[string]bool{}
+	seenPorts := map[int]bool{}
+
+	for _, field := range strings.Fields(value) {
+		name, portStr, ok := strings.Cut(field, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid TCP entrypoint %q, expected NAME:PORT", field)
+		}
+
+		if !entrypointNameRe.MatchString(name) {
+			return nil, fmt.Errorf("invalid TCP entrypoint name %q", name)
+		}
+
+		for _, reserved := range reservedEntrypoints {
+			if name == reserved {
+				return nil, fmt.Errorf("TCP entrypoint name %q is reserved", name)
+			}
+		}
+
+		port, err := strconv.Atoi(portStr)
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid TCP entrypoint port %q", portStr)
+		}
+
+		if port == 53 || port == 80 || port == 443 {
+			return nil, fmt.Errorf("TCP entrypoint port %d is used by the stack", port)
+		}
+
+		if seenNames[name] || seenPorts[port] {
+			return nil, fmt.Errorf("duplicate TCP entrypoint %q", field)
+		}
+		seenNames[name] = true
+		seenPorts[port] = true
+
+		entrypoints = append(entrypoints, TCPEntrypoint{Name: name, Port: port})
+	}
+
+	return entrypoints, nil
+}
+
+func effectiveTCPEntrypoints() ([]TCPEntrypoint, error) {
+	value, source := settingValue("TCP_ENTRYPOINTS")
+
+	entrypoints, err := parseTCPEntrypoints(value)
+	if err != nil {
+		return nil, fmt.Errorf("TCP_ENTRYPOINTS (%s): %w", source, err)
+	}
+
+	return entrypoints, nil
+}
+
+func renderExposeLabels(name string, hosts []string, port int, tcpEntrypoint string) string {
+	var b strings.Builder
+
+	quoted := make([]string, len(hosts))
+
+	b.WriteString("    networks:\n")
+	fmt.Fprintf(&b, "      %s:\n", stackNetwork)
+	b.WriteString("    labels:\n")
+	b.WriteString("      traefik.enable: true\n")
+	fmt.Fprintf(&b, "      # %s\n", name)
+
+	if tcpEntrypoint != "" {
+		for i, host := range hosts {
+			quoted[i] = fmt.Sprintf("HostSNI(`%s`)", host)
+		}
+		fmt.Fprintf(&b, "      traefik.tcp.routers.%s.rule: %s\n", name, strings.Join(quoted, " || "))
+		fmt.Fprintf(&b, "      traefik.tcp.routers.%s.entrypoints: %s\n", name, tcpEntrypoint)
+		fmt.Fprintf(&b, "      traefik.tcp.routers.%s.tls: true  # terminated by traefik\n", name)
+		fmt.Fprintf(&b, "      traefik.tcp.routers.%s.service: %s\n", name, name)
+		fmt.Fprintf(&b, "      traefik.tcp.services.%s.loadbalancer.server.port: %d\n", name, port)
+
+		return b.String()
+	}
+
+	for i, host := range hosts {
+		quoted[i] = fmt.Sprintf("Host(`%s`)", host)
+	}
+	fmt.Fprintf(&b, "      traefik.http.routers.%s.rule: %s\n", name, strings.Join(quoted, " || "))
+	fmt.Fprintf(&b, "      traefik.http.routers.%s.entrypoints: https\n", name)
+	fmt.Fprintf(&b, "      traefik.http.routers.%s.service: %s\n", name, name)
+	fmt.Fprintf(&b, "      traefik.http.services.%s.loadbalancer.server.port: %d\n", name, port)
+
+	return b.String()
+}
+
+var cmdExpose = &cobra.Command{
+	Use:   "expose NAME",
+	Short: "Generate traefik labels to expose a service via the stack",
+	Example: fmt.Sprintf(`  %[1]s expose app --host app.my.test --port 8080
+  %[1]s expose app_db --host db.app.test --port 5432 --tcp postgres`, appName),
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		hosts, _ := cmd.Flags().GetStringSlice("host")
+		port, _ := cmd.Flags().GetInt("port")
+		tcpEntrypoint, _ := cmd.Flags().GetString("tcp")
+
+		if !entrypointNameRe.MatchString(name) {
+			return fmt.Errorf("invalid name %q", name)
+		}
+
+		if len(hosts) == 0 {
+			return fmt.Errorf("at least one --host is required")
+		}
+
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("invalid --port %d", port)
+		}
+
+		if tcpEntrypoint != "" {
+			entrypoints, err := effectiveTCPEntrypoints()
+			if err != nil {
+				return err
+			}
+
+			found := false
+			for _, ep := range entrypoints {
+				if ep.Name == tcpEntrypoint {
+					found = true
+					fmt.Printf("# %s:%d reaches this service over TLS\n", hosts[0], ep.Port)
+				}
+			}
+
+			if !found {
+				return fmt.Errorf("TCP entrypoint %q is not configured, enable it using '%s settings set TCP_ENTRYPOINTS=...' command", tcpEntrypoint, appName)
+			}
+		}
+
+		fmt.Print(renderExposeLabels(name, hosts, port, tcpEntrypoint))
+
+		return nil
+	},
+}
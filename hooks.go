@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+)
+
+const hooksURL = "https://hooks.local.test/"
+
+// Hook mirrors webhook recorded by hooks.local.test, served by services/inspect.
+type Hook struct {
+	ID         int64               `json:"id"`
+	Channel    string              `json:"channel"`
+	Time       time.Time           `json:"time"`
+	Method     string              `json:"method"`
+	Host       string              `json:"host"`
+	Path       string              `json:"path"`
+	Query      string              `json:"query,omitempty"`
+	RemoteAddr string              `json:"remote_addr"`
+	Headers    map[string][]string `json:"headers"`
+	Body       []byte              `json:"body"`
+	Size       int64               `json:"size"`
+	Truncated  bool                `json:"truncated,omitempty"`
+}
+
+// headers set by transport or traefik, not by webhook sender
+var hookSkipHeaders = map[string]bool{
+	"Accept-Encoding":    true, // let client negotiate and decode response
+	"Connection":         true,
+	"Content-Length":     true,
+	"Host":               true,
+	"Keep-Alive":         true,
+	"Proxy-Connection":   true,
+	"Te":                 true,
+	"Trailer":            true,
+	"Transfer-Encoding":  true,
+	"Upgrade":            true,
+	"X-Forwarded-For":    true,
+	"X-Forwarded-Host":   true,
+	"X-Forwarded-Port":   true,
+	"X-Forwarded-Proto":  true,
+	"X-Forwarded-Server": true,
+	"X-Real-Ip":          true,
+}
+
+func hooksGet(client *http.Client, path string, v any) error {
+	resp, err := client.Get(hooksURL + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("GET %s%s: %s %s", hooksURL, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func fetchHook(client *http.Client, arg string) (*Hook, error) {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hook id %q", arg)
+	}
+
+	var hook Hook
+	if err := hooksGet(client, fmt.Sprintf("api/hooks/%d", id), &hook); err != nil {
+		return nil, err
+	}
+	return &hook, nil
+}
+
+// replayHook re-sends recorded payload with its original method and headers.
+func replayHook(client *http.Client, hook *Hook, target string) (*http.Response, error) {
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid target URL %q", target)
+	}
+
+	req, err := http.NewRequest(hook.Method, u.String(), bytes.NewReader(hook.Body))
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range hook.Headers {
+		if hookSkipHeaders[http.CanonicalHeaderKey(name)] {
+			continue
+		}
+		req.Header[name] = values
+	}
+
+	return client.Do(req)
+}
+
+func hookContentType(hook Hook) string {
+	for name, values := range hook.Headers {
+		if http.CanonicalHeaderKey(name) == "Content-Type" && len(values) > 0 {
+			return values[0]
+		}
+	}
+	return "-"
+}
+
+const hooksTableFormat = "%-6s %-19s %-16s %-7s %8s  %s\n"
+
+var cmdHooks = &cobra.Command{
+	Use:   "hooks",
+	Short: "Inspect and replay webhooks recorded by hooks.local.test service",
+}
+
+var cmdHooksList = &cobra.Command{
+	Use:   "list",
+	Short: "List recorded webhooks",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		channel, _ := cmd.Flags().GetString("channel")
+
+		client, err := stackHTTPClient(10 * time.Second)
+		if err != nil {
+			return err
+		}
+
+		var hooks []Hook
+		if err := hooksGet(client, "api/hooks?channel="+url.QueryEscape(channel), &hooks); err != nil {
+			return err
+		}
+
+		if len(hooks) == 0 {
+			fmt.Printf("No webhooks recorded yet, send them to %sc/<name>\n", hooksURL)
+			return nil
+		}
+
+		fmt.Printf(hooksTableFormat, "ID", "TIME", "CHANNEL", "METHOD", "SIZE", "CONTENT-TYPE")
+		for _, hook := range hooks {
+			fmt.Printf(hooksTableFormat,
+				strconv.FormatInt(hook.ID, 10), hook.Time.Local().Format("2006-01-02 15:04:05"),
+				hook.Channel, hook.Method, strconv.FormatInt(hook.Size, 10), hookContentType(hook))
+		}
+
+		return nil
+	},
+}
+
+var cmdHooksShow = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show recorded webhook with headers and payload",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := stackHTTPClient(10 * time.Second)
+		if err != nil {
+			return err
+		}
+
+		hook, err := fetchHook(client, args[0])
+		if err != nil {
+			return err
+		}
+
+		path := hook.Path
+		if hook.Query != "" {
+			path += "?" + hook.Query
+		}
+
+		fmt.Printf("Hook:     #%d\n", hook.ID)
+		fmt.Printf("Channel:  %s\n", hook.Channel)
+		fmt.Printf("Received: %s from %s\n", hook.Time.Local().Format(time.RFC3339), hook.RemoteAddr)
+		fmt.Printf("Request:  %s %s\n\n", hook.Method, path)
+
+		names := make([]string, 0, len(hook.Headers))
+		for name := range hook.Headers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			fmt.Printf("%s: %s\n", name, strings.Join(hook.Headers[name], ", "))
+		}
+
+		fmt.Println()
+
+		if utf8.Valid(hook.Body) {
+			fmt.Println(string(hook.Body))
+		} else {
+			fmt.Printf("(binary payload, %d bytes)\n", len(hook.Body))
+		}
+
+		if hook.Truncated {
+			fmt.Printf("\nWARN: payload is truncated, %d of %d bytes recorded\n", len(hook.Body), hook.Size)
+		}
+
+		return nil
+	},
+}
+
+var cmdHooksReplay = &cobra.Command{
+	Use:   "replay <id> --to <url>",
+	Short: "Re-send recorded webhook with its original headers to local app",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		target, _ := cmd.Flags().GetString("to")
+
+		client, err := stackHTTPClient(30 * time.Second)
+		if err != nil {
+			return err
+		}
+
+		hook, err := fetchHook(client, args[0])
+		if err != nil {
+			return err
+		}
+
+		if hook.Truncated {
+			return fmt.Errorf("hook #%d payload is truncated, refusing to replay", hook.ID)
+		}
+
+		fmt.Printf("Replaying #%d %s (%d bytes) to %s ...\n", hook.ID, hook.Method, len(hook.Body), target)
+
+		resp, err := replayHook(client, hook, target)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+
+		fmt.Printf("Response: %s\n", resp.Status)
+		if len(body) > 0 {
+			fmt.Printf("\n%s\n", strings.TrimRight(string(body), "\n"))
+		}
+
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("webhook handler responded with %s", resp.Status)
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const inspectURL = "https://inspect.local.test/_inspect/"
+
+// InspectedRequest mirrors request captured by services/inspect.
+type InspectedRequest struct {
+	ID         int64               `json:"id"`
+	Time       time.Time           `json:"time"`
+	Method     string              `json:"method"`
+	Host       string              `json:"host"`
+	Path       string              `json:"path"`
+	Query      string              `json:"query,omitempty"`
+	Proto      string              `json:"proto"`
+	RemoteAddr string              `json:"remote_addr"`
+	Headers    map[string][]string `json:"headers"`
+	Body       string              `json:"body,omitempty"`
+	BodyBase64 string              `json:"body_base64,omitempty"`
+	Size       int64               `json:"size"`
+	Truncated  bool                `json:"truncated,omitempty"`
+}
+
+func (r InspectedRequest) URL() string {
+	url := r.Host + r.Path
+	if r.Query != "" {
+		url += "?" + r.Query
+	}
+	return url
+}
+
+func fetchInspectedRequests(client *http.Client) ([]InspectedRequest, error) {
+	resp, err := client.Get(inspectURL + "api/requests")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %sapi/requests: %s", inspectURL, resp.Status)
+	}
+
+	var list []InspectedRequest
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// streamInspectedRequests reads server-sent events of inspect service until ctx is done.
+func streamInspectedRequests(ctx context.Context, client *http.Client, fn func(InspectedRequest) error) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, inspectURL+"api/stream", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %sapi/stream: %s", inspectURL, resp.Status)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue // id, keepalive comments and event separators
+		}
+
+		var captured InspectedRequest
+		if err := json.Unmarshal([]byte(data), &captured); err != nil {
+			return fmt.Errorf("malformed event: %w", err)
+		}
+
+		if err := fn(captured); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return err
+	}
+
+	return nil
+}
+
+func printInspectedRequest(r InspectedRequest, headers, body bool) {
+	fmt.Printf("#%-5d %s %-7s %s (%d bytes)\n", r.ID, r.Time.Local().Format("15:04:05.000"), r.Method, r.URL(), r.Size)
+
+	if headers {
+		names := make([]string, 0, len(r.Headers))
+		for name := range r.Headers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			fmt.Printf("    %s: %s\n", name, strings.Join(r.Headers[name], ", "))
+		}
+	}
+
+	if body {
+		switch {
+		case r.BodyBase64 != "":
+			fmt.Printf("    (binary body, %d bytes)\n", r.Size)
+		case r.Body != "":
+			for _, line := range strings.Split(strings.TrimRight(r.Body, "\n"), "\n") {
+				fmt.Printf("    | %s\n", line)
+			}
+		}
+		if r.Truncated {
+			fmt.Printf("    ... truncated\n")
+		}
+	}
+}
+
+var cmdInspect = &cobra.Command{
+	Use:   "inspect",
+	Short: "Inspect requests captured by inspect.local.test service",
+}
+
+var cmdInspectTail = &cobra.Command{
+	Use:   "tail",
+	Short: "Stream captured requests",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		last, _ := cmd.Flags().GetInt("last")
+		headers, _ := cmd.Flags().GetBool("headers")
+		body, _ := cmd.Flags().GetBool("body")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		// no client timeout, as stream is long living
+		client, err := stackHTTPClient(0)
+		if err != nil {
+			return err
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+
+		emit := func(r InspectedRequest) error {
+			if asJSON {
+				return enc.Encode(r)
+			}
+			printInspectedRequest(r, headers, body)
+			return nil
+		}
+
+		if last > 0 {
+			list, err := fetchInspectedRequests(client)
+			if err != nil {
+				return err
+			}
+			for _, r := range list[max(len(list)-last, 0):] {
+				if err := emit(r); err != nil {
+					return err
+				}
+			}
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		if !asJSON {
+			fmt.Printf("Waiting for requests to %s ...\n", inspectURL)
+		}
+
+		return streamInspectedRequests(ctx, client, emit)
+	},
+}
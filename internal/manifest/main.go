@@ -1,7 +1,10 @@
 package main
 
 import (
+	"crypto/ed25519"
 	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/fs"
@@ -11,13 +14,20 @@ import (
 	"strings"
 
 	"github.com/bmatcuk/doublestar/v4"
+	"github.com/jaymecd/localtest/internal/release"
 )
 
 const outFile = "localtest_gen.go"
+const manifestFile = "manifest.json"
+
+// base64 encoded ed25519 seed or private key of the release,
+// or "unsigned" for development builds
+const signingKeyEnv = "LOCALTEST_SIGNING_KEY"
 
 var searchPatterns = []string{
 	"compose.yaml",
 	"services/**",
+	"addons/*.yaml",
 }
 
 var ignorePatterns = normalizeIgnorePatterns([]string{
@@ -33,6 +43,20 @@ type StackFileMeta struct {
 	Sha256 string
 }
 
+type ManifestFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Perm   string `json:"perm"`
+	Sha256 string `json:"sha256"`
+}
+
+type Manifest struct {
+	Digest    string         `json:"digest"`
+	Signature string         `json:"signature"`
+	PublicKey string         `json:"public_key"`
+	Files     []ManifestFile `json:"files"`
+}
+
 func normalizeIgnorePatterns(patterns []string) []string {
 	var out []string
 	for _, p := range patterns {
@@ -58,6 +82,71 @@ func computeSHA256(path string) (string, error) {
 	return fmt.Sprintf("%x", h.Sum(nil)), nil
 }
 
+// computeDigest hashes sorted "path\tperm\tsha256" lines, so digest changes
+// with any file content, permission, addition or removal.
+func computeDigest(filesMeta []StackFileMeta) string {
+	h := sha256.New()
+	for _, file := range filesMeta {
+		fmt.Fprintf(h, "%s\t0%o\t%s\n", file.Path, file.Perm, file.Sha256)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// signingKey loads release key, matching release.PublicKey, or returns nil
+// for development builds explicitly marked as unsigned.
+func signingKey() (ed25519.PrivateKey, error) {
+	value := os.Getenv(signingKeyEnv)
+	switch value {
+	case "":
+		return nil, fmt.Errorf("%s is not set, provide release key or %q for development builds", signingKeyEnv, release.Unsigned)
+	case release.Unsigned:
+		fmt.Printf("WARN: %s=%s, embedding unsigned manifest of development build\n", signingKeyEnv, release.Unsigned)
+		return nil, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", signingKeyEnv, err)
+	}
+
+	var key ed25519.PrivateKey
+
+	switch len(raw) {
+	case ed25519.SeedSize:
+		key = ed25519.NewKeyFromSeed(raw)
+	case ed25519.PrivateKeySize:
+		key = ed25519.PrivateKey(raw)
+	default:
+		return nil, fmt.Errorf("%s: unexpected key size %d", signingKeyEnv, len(raw))
+	}
+
+	publicKey := base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+	if publicKey != release.PublicKey {
+		return nil, fmt.Errorf("%s: public key %s does not match release one %s", signingKeyEnv, publicKey, release.PublicKey)
+	}
+
+	return key, nil
+}
+
+func writeManifest(digest, signature, publicKey string, filesMeta []StackFileMeta) error {
+	manifest := Manifest{Digest: digest, Signature: signature, PublicKey: publicKey, Files: []ManifestFile{}}
+	for _, file := range filesMeta {
+		manifest.Files = append(manifest.Files, ManifestFile{
+			Path:   file.Path,
+			Size:   file.Size,
+			Perm:   fmt.Sprintf("0%o", file.Perm),
+			Sha256: file.Sha256,
+		})
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(manifestFile, append(data, '\n'), 0o644)
+}
+
 func main() {
 	var filesMeta []StackFileMeta
 
@@ -105,6 +194,24 @@ func main() {
 		return filesMeta[i].Path < filesMeta[j].Path
 	})
 
+	digest := computeDigest(filesMeta)
+
+	key, err := signingKey()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	var signature, publicKey string
+	if key != nil {
+		signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(digest)))
+		publicKey = release.PublicKey
+	}
+
+	if err := writeManifest(digest, signature, publicKey, filesMeta); err != nil {
+		panic(err)
+	}
+
 	f, err := os.Create(outFile)
 	if err != nil {
 		panic(err)
@@ -122,6 +229,12 @@ func main() {
 	}
 	fmt.Fprintln(f, "var stackFilesFS embed.FS")
 	fmt.Fprintln(f, "")
+	fmt.Fprintln(f, "//go:embed ", manifestFile)
+	fmt.Fprintln(f, "var stackManifestJSON []byte")
+	fmt.Fprintln(f, "")
+	fmt.Fprintf(f, "const stackManifestDigest = %q\n", digest)
+	fmt.Fprintf(f, "const stackManifestSignature = %q\n", signature)
+	fmt.Fprintln(f, "")
 	fmt.Fprintln(f, "type StackFileMeta struct {")
 	fmt.Fprintln(f, "\tPath string")
 	fmt.Fprintln(f, "\tSize int64")
@@ -139,4 +252,5 @@ func main() {
 		fmt.Printf("%s %s (%.1fK)\n", file.Perm.String(), file.Path, float64(file.Size)/1024)
 	}
 	fmt.Fprintln(f, "}")
+	fmt.Printf("Manifest digest: %s\n", digest)
 }
@@ -0,0 +1,10 @@
+// Package release holds trust anchors of released localtest binaries.
+package release
+
+// PublicKey verifies manifest of embedded stack files, signed by release
+// builds using LOCALTEST_SIGNING_KEY. Rotating it invalidates older manifests.
+const PublicKey = "haDWm6jFjD+8Ep0+G8JK8qBQA/nMohtIAAcTlsjMFQs="
+
+// Unsigned is LOCALTEST_SIGNING_KEY value of development builds, which
+// embed unsigned manifest explicitly.
+const Unsigned = "unsigned"
@@ -57,24 +57,33 @@ func fileExists(path string) bool {
 	return !info.IsDir()
 }
 
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
 type BinaryVersion struct {
 	Version string
 	Commit  string
 	Date    string
 }
 
+// v2 adds manifest digest of synced stack files
+const stackSpecVersion = 2
+
 type StackVersion struct {
 	SpecVersion uint8
 	Binary      BinaryVersion
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
+	Digest      string
 }
 
-func NewStackVersionV1() *StackVersion {
+func NewStackVersion() *StackVersion {
 	now := time.Now().Local()
 
 	sv := &StackVersion{
-		SpecVersion: 1,
+		SpecVersion: stackSpecVersion,
 		Binary: BinaryVersion{
 			Version: buildVersion,
 			Commit:  buildCommit,
@@ -82,13 +91,16 @@ func NewStackVersionV1() *StackVersion {
 		},
 		CreatedAt: now,
 		UpdatedAt: now,
+		Digest:    stackManifestDigest,
 	}
 
 	return sv
 }
 
 func (sv *StackVersion) RecordUpdate() {
+	sv.SpecVersion = stackSpecVersion
 	sv.UpdatedAt = time.Now().Local()
+	sv.Digest = stackManifestDigest
 
 	if sv.Binary.Version != buildVersion {
 		sv.Binary.Version = buildVersion
@@ -97,8 +109,9 @@ func (sv *StackVersion) RecordUpdate() {
 	}
 }
 
-func (sv StackVersion) IsSameBinaryVersion() bool {
-	return sv.Binary.Version == buildVersion
+// IsSameManifest reports whether stack content matches the one embedded into binary.
+func (sv StackVersion) IsSameManifest() bool {
+	return sv.Digest == stackManifestDigest
 }
 
 func (sv StackVersion) SaveToFile(path string) error {
@@ -175,6 +188,12 @@ func (s StackVersion) MarshalBinary() ([]byte, error) {
 		return nil, err
 	}
 
+	if s.SpecVersion >= 2 {
+		if err := writeString(s.Digest); err != nil {
+			return nil, err
+		}
+	}
+
 	return buf.Bytes(), nil
 }
 
@@ -237,6 +256,12 @@ func (s *StackVersion) UnmarshalBinary(data []byte) error {
 		return err
 	}
 
+	if s.SpecVersion >= 2 {
+		if s.Digest, err = readString(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -390,20 +415,42 @@ func syncStack(update bool) (bool, error) {
 	infoFile := filepath.Join(dest, stackInfoFile)
 	rebuildFile := filepath.Join(dest, stackRebuildFile)
 
-	sv := NewStackVersionV1()
+	if err := recoverStackDir(dest); err != nil {
+		return false, err
+	}
 
-	rebuild := fileExists(rebuildFile)
+	sv := NewStackVersion()
+	fresh := false
+
+	reasons, err := loadRebuildReasons(rebuildFile)
+	if err != nil {
+		return false, err
+	}
 
 	if err := sv.LoadFromFile(infoFile); err != nil {
 		if !errors.Is(err, os.ErrNotExist) && !errors.Is(err, ErrMagicHeaderInvalid) {
 			return false, fmt.Errorf("stack exists, failed to read info (%w)\n", err)
 		}
 
+		fresh = errors.Is(err, os.ErrNotExist)
 		update = true
 	}
 
 	if update {
-		if err := extractStackFiles(dest, true); err != nil {
+		if err := verifyEmbeddedManifest(); err != nil {
+			return false, err
+		}
+
+		caFile := filepath.Join(dest, "certs", "rootCA.pem")
+		oldCA, _ := os.ReadFile(caFile)
+		oldCompose, _ := os.ReadFile(filepath.Join(dest, "compose.yaml"))
+
+		changes, err := planStackSync(dest)
+		if err != nil {
+			return false, err
+		}
+
+		if err := extractStackFiles(dest, changes); err != nil {
 			return false, err
 		}
 
@@ -411,7 +458,22 @@ func syncStack(update bool) (bool, error) {
 			return false, err
 		}
 
-		rebuild = rebuild || !sv.IsSameBinaryVersion()
+		// fresh stack is built anyway
+		if !fresh {
+			reasons = mergeRebuildReasons(reasons, contextRebuildReasons(changes)...)
+		}
+
+		if newCompose, _ := os.ReadFile(filepath.Join(dest, "compose.yaml")); oldCompose != nil {
+			reasons = mergeRebuildReasons(reasons, buildArgsChanges(oldCompose, newCompose)...)
+		}
+
+		if newCA, _ := os.ReadFile(caFile); oldCA != nil && !bytes.Equal(oldCA, newCA) {
+			reasons = mergeRebuildReasons(reasons, RebuildReason{
+				Kind:    RebuildCA,
+				Service: "mkcert",
+				Detail:  "local Root CA changed",
+			})
+		}
 
 		sv.RecordUpdate()
 
@@ -419,12 +481,14 @@ func syncStack(update bool) (bool, error) {
 			return false, fmt.Errorf("failed saving, err: %w\n", err)
 		}
 
-		if rebuild {
-			os.WriteFile(rebuildFile, []byte{}, 0600)
+		if len(reasons) > 0 {
+			if err := saveRebuildReasons(rebuildFile, reasons); err != nil {
+				return false, err
+			}
 		}
 	}
 
-	return rebuild, nil
+	return len(reasons) > 0, nil
 }
 
 func verifyAllSHA256(dest string, verbose bool) error {
@@ -470,67 +534,92 @@ func showInfo(dest string) error {
 		return fmt.Errorf("stack exists, failed to read info (%w)\n", err)
 	}
 
-	rebuild := fileExists(rebuildFile)
+	reasons, err := loadRebuildReasons(rebuildFile)
+	if err != nil {
+		return err
+	}
 
 	fmt.Printf("Stack created at:  %s (%s ago)\n", sv.CreatedAt.Format(time.RFC3339), time.Since(sv.CreatedAt).Round(time.Second))
 	fmt.Printf("Stack updated at:  %s (%s ago)\n", sv.UpdatedAt.Format(time.RFC3339), time.Since(sv.UpdatedAt).Round(time.Second))
 	fmt.Printf("Stack version:     %s (Built on %s from %s commit)\n", sv.Binary.Version, sv.Binary.Commit, sv.Binary.Date)
 	fmt.Printf("Binary version:    %s (Built on %s from %s commit)\n", buildVersion, buildCommit, buildDate)
+	fmt.Printf("Stack digest:      %s\n", renderDigest(sv.Digest))
+	fmt.Printf("Binary digest:     %s\n", stackManifestDigest)
+	fmt.Printf("Bind IP:           %s\n", renderBindIP())
+
+	leaf, leafErr := leafCertificate()
+	ca, caErr := rootCACertificate(dest)
+	fmt.Printf("Leaf cert expiry:  %s\n", renderDaysLeft(leaf, leafErr))
+	fmt.Printf("Root CA expiry:    %s\n", renderDaysLeft(ca, caErr))
 
-	if !sv.IsSameBinaryVersion() {
-		fmt.Printf("\nINFO: stack and binary versions do not match, please run 'sync' command.\n")
+	if !sv.IsSameManifest() {
+		fmt.Printf("\nINFO: stack and binary manifests do not match, please run 'sync' command.\n")
 	}
 
-	if rebuild {
-		fmt.Printf("\nINFO: stack rebuild is pending, please run 'up' command.\n")
+	if len(reasons) > 0 {
+		fmt.Printf("\nINFO: stack rebuild is pending, please run 'up' command:\n")
+		printRebuildReasons(reasons)
 	}
 
 	return nil
 }
 
-func isDirEmpty(name string) (bool, error) {
-	f, err := os.Open(name)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return true, nil
-		}
-		return false, err
+// extractStackFiles applies changes in staging copy of stack directory,
+// swapping it in only once all files are verified.
+func extractStackFiles(dest string, changes []StackChange) error {
+	if len(changes) == 0 {
+		fmt.Printf("Stack files in %s directory are up to date.\n", dest)
+		return verifyAllSHA256(dest, false)
+	}
+
+	fmt.Printf("Syncing %d changed files to %s directory:\n", len(changes), dest)
+
+	staging := stackStagingDir(dest)
+
+	skip := map[string]bool{}
+	for _, change := range changes {
+		skip[change.Path] = true
 	}
-	defer f.Close()
 
-	if _, err = f.Readdirnames(1); err == io.EOF {
-		return true, nil
+	if err := stageStackDir(dest, staging, skip); err != nil {
+		os.RemoveAll(staging)
+		return fmt.Errorf("failed to stage stack directory: %w", err)
 	}
-	return false, err
+
+	if err := writeStackChanges(staging, changes); err != nil {
+		os.RemoveAll(staging)
+		return err
+	}
+
+	if err := verifyAllSHA256(staging, false); err != nil {
+		os.RemoveAll(staging)
+		return err
+	}
+
+	return swapStackDir(dest, staging)
 }
 
-func extractStackFiles(dest string, cleanup bool) error {
-	if cleanup {
-		if err := os.RemoveAll(dest); err != nil {
-			return fmt.Errorf("failed to remove stack dir: %w", err)
-		}
-	} else {
-		isEmpty, err := isDirEmpty(dest)
-		if err != nil {
-			return err
-		}
+func writeStackChanges(dest string, changes []StackChange) error {
+	for _, change := range changes {
+		dst := filepath.Join(dest, change.Path)
 
-		if !isEmpty {
-			return fmt.Errorf("Directory is not empty.")
+		if change.Op == StackFileRemove {
+			// not staged, so just clean up directories left empty
+			fmt.Printf("%-6s %s\n", change.Op, change.Path)
+			pruneEmptyDirs(dest, dst)
+			continue
 		}
-	}
 
-	fmt.Printf("Extracting %d files to %s directory:\n", len(stackFilesMeta), dest)
-	for _, file := range stackFilesMeta {
+		file := change.File
+
 		data, err := stackFilesFS.ReadFile(file.Path)
 		if err != nil {
 			return err
 		}
-		dst := filepath.Join(dest, file.Path)
 		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
 			return err
 		}
-		fmt.Printf("%s %s (%.1fK)\n", file.Perm.String(), file.Path, float64(file.Size)/1024)
+		fmt.Printf("%-6s %s %s (%.1fK)\n", change.Op, file.Perm.String(), file.Path, float64(file.Size)/1024)
 		if err := os.WriteFile(dst, data, file.Perm); err != nil {
 			return err
 		}
@@ -540,14 +629,10 @@ func extractStackFiles(dest string, cleanup bool) error {
 			return err
 		}
 		if !valid {
-			return fmt.Errorf("Checksum for %q is", file.Path)
+			return fmt.Errorf("checksum for %q does not match embedded one after extraction", file.Path)
 		}
 	}
 
-	if err := verifyAllSHA256(dest, false); err != nil {
-		return err
-	}
-
 	return nil
 }
 
@@ -614,9 +699,75 @@ func main() {
 }
 
 func init() {
+	cmdSync.Flags().Bool("dry-run", false, "show unified diff of pending changes, without touching disk")
+
 	cmdRm.Flags().BoolP("all", "a", false, "all resources such as volumes, images & etc ...")
 
-	rootCmd.AddCommand(cmdSync, cmdRm, cmdVerify, cmdInfo)
+	cmdRun.Flags().String("image", "alpine", "container image to run")
+	cmdRun.Flags().SetInterspersed(false)
+
+	cmdExpose.Flags().StringSlice("host", nil, "FQDN to route, could be repeated")
+	cmdExpose.Flags().Int("port", 80, "container port to route to")
+	cmdExpose.Flags().String("tcp", "", "TCP entrypoint name to route via HostSNI, eg. postgres")
+
+	cmdSettings.AddCommand(cmdSettingsList, cmdSettingsSet, cmdSettingsUnset)
+
+	cmdDNSProxy.PersistentFlags().String("listen", "127.0.0.1:53", "address to listen on (udp/tcp)")
+	cmdDNSProxy.PersistentFlags().String("upstream", "", "stack nameserver address (default DOCKER_DEFAULT_IP:53)")
+	cmdDNSProxy.PersistentFlags().StringSlice("tld", []string{"test"}, "TLD to forward, could be repeated")
+	cmdDNSProxy.PersistentFlags().String("pidfile", "", fmt.Sprintf("pidfile path (default %s)", filepath.Join(runtimeDir(), dnsProxyPidFile)))
+	cmdDNSProxy.PersistentFlags().BoolP("verbose", "v", false, "log every query")
+	cmdDNSProxy.AddCommand(cmdDNSProxyRun, cmdDNSProxyStart, cmdDNSProxyStop, cmdDNSProxyStatus)
+
+	cmdDNS.AddCommand(cmdDNSAdd, cmdDNSRm, cmdDNSList)
+
+	cmdBackup.Flags().Bool("portainer", false, "include portainer data volume")
+
+	cmdBundle.AddCommand(cmdBundleExport, cmdBundleImport)
+
+	cmdPrefetch.Flags().Bool("check", false, "only report missing images, do not fetch")
+
+	cmdVersions.PersistentFlags().String("registry", defaultRegistryURL, "registry v2 API to validate tags against")
+	cmdVersions.AddCommand(cmdVersionsList, cmdVersionsSet, cmdVersionsCheck)
+
+	cmdVerify.Flags().String("manifest", "", "verify stack against signed manifest `file.json`")
+	cmdVerify.Flags().Bool("certs", false, "verify certificates in certs volume against their checksums")
+	cmdVerify.Flags().Bool("repair", false, "restore missing or modified stack files")
+	cmdVerify.MarkFlagsMutuallyExclusive("manifest", "certs", "repair")
+
+	cmdCerts.AddCommand(cmdCertsStatus)
+
+	cmdLogsAccess.Flags().StringSlice("host", nil, "filter by request host, glob patterns allowed (eg. *.local.test)")
+	cmdLogsAccess.Flags().StringSlice("router", nil, "filter by traefik router name")
+	cmdLogsAccess.Flags().StringSlice("status", nil, "filter by status class or code (eg. 5xx, 404)")
+	cmdLogsAccess.Flags().String("path", "", "filter by request path regex")
+	cmdLogsAccess.Flags().Duration("min-duration", 0, "filter requests slower than duration (eg. 250ms)")
+	cmdLogsAccess.Flags().BoolP("follow", "f", false, "follow log output")
+	cmdLogsAccess.Flags().String("since", "", "show logs since timestamp or relative (eg. 10m)")
+	cmdLogsAccess.Flags().String("tail", "", "number of lines to scan from the end of the logs")
+	cmdLogsAccess.Flags().Bool("json", false, "print matching entries as JSON lines")
+	cmdLogs.AddCommand(cmdLogsAccess)
+
+	cmdTop.Flags().Duration("window", 10*time.Second, "sliding window to compute rates and latencies over")
+	cmdTop.Flags().Duration("interval", time.Second, "refresh interval")
+
+	cmdInspectTail.Flags().IntP("last", "n", 0, "print last N captured requests first")
+	cmdInspectTail.Flags().Bool("headers", false, "print request headers")
+	cmdInspectTail.Flags().Bool("body", false, "print request body")
+	cmdInspectTail.Flags().Bool("json", false, "print captured requests as JSON lines")
+	cmdInspect.AddCommand(cmdInspectTail)
+
+	cmdHooksList.Flags().String("channel", "", "list webhooks of given channel only")
+	cmdHooksReplay.Flags().String("to", "", "URL of local app webhook handler (eg. https://app.my.test/webhook)")
+	cmdHooksReplay.MarkFlagRequired("to")
+	cmdHooks.AddCommand(cmdHooksList, cmdHooksShow, cmdHooksReplay)
+
+	cmdAddons.AddCommand(cmdAddonsList, cmdAddonsEnable, cmdAddonsDisable)
+
+	cmdManifest.Flags().Bool("digest", false, "print digest only")
+	cmdManifest.Flags().Bool("json", false, "print manifest.json as embedded")
+
+	rootCmd.AddCommand(cmdSync, cmdRm, cmdVerify, cmdInfo, cmdRun, cmdExpose, cmdSettings, cmdDNSProxy, cmdDNS, cmdDoctor, cmdBackup, cmdRestore, cmdBundle, cmdPrefetch, cmdVersions, cmdManifest, cmdCerts, cmdTop, cmdInspect, cmdHooks, cmdAddons)
 	// docker compose proxies
 	rootCmd.AddCommand(cmdUp, cmdDown, cmdLogs, cmdPs)
 
@@ -634,16 +785,36 @@ var cmdSync = &cobra.Command{
 	Use:   "sync",
 	Short: "Sync the stack",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			return showStackSyncPlan(stackDir())
+		}
+
+		lock, err := lockStack(stackDir(), stackLockTimeout)
+		if err != nil {
+			return err
+		}
+		defer lock.Unlock()
+
 		rebuild, err := syncStack(true)
 
 		if err != nil {
 			return err
 		}
 
+		if err := checkBindIP(); err != nil {
+			return err
+		}
+
 		fmt.Printf("Synchronization DONE\n")
 
 		if rebuild {
-			fmt.Printf("\nINFO: stack rebuild is pending, please run 'up' command.\n")
+			reasons, err := loadRebuildReasons(filepath.Join(stackDir(), stackRebuildFile))
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("\nINFO: stack rebuild is pending, please run 'up' command:\n")
+			printRebuildReasons(reasons)
 		}
 
 		return nil
@@ -674,6 +845,12 @@ var cmdRm = &cobra.Command{
 			return fmt.Errorf("decided to postpone removal")
 		}
 
+		lock, err := lockStack(stackDir(), stackLockTimeout)
+		if err != nil {
+			return err
+		}
+		defer lock.Unlock()
+
 		if err := runDockerCompose(args...); err != nil {
 			if !errors.Is(err, ErrStackNotExist) {
 				return err
@@ -698,6 +875,36 @@ var cmdVerify = &cobra.Command{
 	Use:   "verify",
 	Short: "Verify integrity",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if certs, _ := cmd.Flags().GetBool("certs"); certs {
+			return verifyCerts(stackDir(), true)
+		}
+
+		if file, _ := cmd.Flags().GetString("manifest"); file != "" {
+			m, err := loadManifest(file)
+			if err != nil {
+				return err
+			}
+			return verifyStackAgainstManifest(stackDir(), m, true)
+		}
+
+		if err := verifyEmbeddedManifest(); err != nil {
+			return err
+		}
+		fmt.Printf("Manifest signature: OK (%s)\n", stackManifestDigest)
+
+		if repair, _ := cmd.Flags().GetBool("repair"); repair {
+			lock, err := lockStack(stackDir(), stackLockTimeout)
+			if err != nil {
+				return err
+			}
+			defer lock.Unlock()
+
+			// restores missing and modified files, marking affected services to be rebuilt
+			if _, err := syncStack(true); err != nil {
+				return err
+			}
+		}
+
 		return verifyAllSHA256(stackDir(), true)
 	},
 }
@@ -715,18 +922,39 @@ var cmdUp = &cobra.Command{
 	Short:              "Spin up the stack via 'docker compose up'",
 	DisableFlagParsing: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		lock, err := lockStack(stackDir(), stackLockTimeout)
+		if err != nil {
+			return err
+		}
+		defer lock.Unlock()
+
 		rebuild, err := syncStack(false)
 		if err != nil {
 			return err
 		}
 
+		if err := checkBindIP(); err != nil {
+			return err
+		}
+
+		if err := checkPortConflicts(); err != nil {
+			return err
+		}
+
 		dest := stackDir()
 		rebuildFile := filepath.Join(dest, stackRebuildFile)
 
 		args = append([]string{"up", "--wait", "--remove-orphans"}, args...)
 
+		reasons, err := loadRebuildReasons(rebuildFile)
+		if err != nil {
+			return err
+		}
+
 		if rebuild {
-			fmt.Printf("Stack upgrade is pending ...\n")
+			fmt.Printf("Stack upgrade is pending:\n")
+			printRebuildReasons(reasons)
+
 			if !HasInternetConnection() {
 				fmt.Printf("WARN: running in OFFLINE mode, upgrade is postponed ;)\n")
 				rebuild = false
@@ -737,7 +965,15 @@ var cmdUp = &cobra.Command{
 		}
 
 		if rebuild {
-			args = append(args, "--always-recreate-deps", "--force-recreate", "--build")
+			args = append(args, "--always-recreate-deps", "--force-recreate")
+
+			if services, all := rebuildTargets(dest, reasons); all {
+				args = append(args, "--build")
+			} else if len(services) > 0 {
+				if err := runDockerCompose(append([]string{"build"}, services...)...); err != nil {
+					return err
+				}
+			}
 		}
 
 		if err := runDockerCompose(args...); err != nil {
@@ -789,21 +1025,43 @@ var cmdPs = &cobra.Command{
 	},
 }
 
-func runDockerCompose(args ...string) error {
+func dockerComposeCommand(args ...string) (*exec.Cmd, error) {
 	dest := stackDir()
 	composeFile := filepath.Join(dest, "compose.yaml")
 
 	if _, err := os.Stat(composeFile); err != nil {
 		if os.IsNotExist(err) {
-			return fmt.Errorf("%w in %s", ErrStackNotExist, dest)
+			return nil, fmt.Errorf("%w in %s", ErrStackNotExist, dest)
 		}
 
-		return err
+		return nil, err
+	}
+
+	fileArgs, err := composeFileArgs(dest)
+	if err != nil {
+		return nil, err
+	}
+
+	cmdArgs := append(append([]string{"compose"}, fileArgs...), args...)
+
+	return exec.Command("docker", cmdArgs...), nil
+}
+
+func dockerComposeOutput(args ...string) ([]byte, error) {
+	cmd, err := dockerComposeCommand(args...)
+	if err != nil {
+		return nil, err
 	}
 
-	cmdArgs := append([]string{"compose", "-f", composeFile}, args...)
+	return cmd.Output()
+}
+
+func runDockerCompose(args ...string) error {
+	cmd, err := dockerComposeCommand(args...)
+	if err != nil {
+		return err
+	}
 
-	cmd := exec.Command("docker", cmdArgs...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
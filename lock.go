@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// stackLockPath is sibling of stack directory, so lock stays in place
+// while stack directory is swapped or removed.
+func stackLockPath(dest string) string { return dest + ".lock" }
+
+const (
+	stackLockTimeout  = 2 * time.Minute
+	stackLockInterval = 200 * time.Millisecond
+	stackLockGrace    = 5 * time.Second // lock file might be created, but not written yet
+)
+
+var ErrStackLocked = errors.New("stack is locked")
+
+// StackLock is advisory lock, serializing commands mutating stack directory.
+type StackLock struct {
+	path  string
+	owner string
+}
+
+type stackLockHolder struct {
+	PID   int
+	Since time.Time
+}
+
+func (h stackLockHolder) String() string {
+	return fmt.Sprintf("PID %d since %s", h.PID, h.Since.Format(time.RFC3339))
+}
+
+// lock file holds single line: PID and acquisition time.
+func readStackLock(path string) (stackLockHolder, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return stackLockHolder{}, "", err
+	}
+
+	content := string(data)
+
+	pid, since, ok := strings.Cut(strings.TrimSpace(content), " ")
+	if !ok {
+		return stackLockHolder{}, content, fmt.Errorf("malformed lock file %q", path)
+	}
+
+	var holder stackLockHolder
+
+	if holder.PID, err = strconv.Atoi(pid); err != nil {
+		return stackLockHolder{}, content, fmt.Errorf("malformed lock file %q: %w", path, err)
+	}
+	if holder.Since, err = time.Parse(time.RFC3339, since); err != nil {
+		return stackLockHolder{}, content, fmt.Errorf("malformed lock file %q: %w", path, err)
+	}
+
+	return holder, content, nil
+}
+
+// isStaleLock reports whether lock is left by dead process.
+func isStaleLock(path string, holder stackLockHolder, err error) bool {
+	if err == nil {
+		return !processAlive(holder.PID)
+	}
+
+	info, statErr := os.Stat(path)
+	return statErr == nil && time.Since(info.ModTime()) > stackLockGrace
+}
+
+// lockStack acquires stack lock, waiting up to timeout for other holder to release it.
+func lockStack(dest string, timeout time.Duration) (*StackLock, error) {
+	path := stackLockPath(dest)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	owner := fmt.Sprintf("%d %s\n", os.Getpid(), time.Now().Format(time.RFC3339))
+	deadline := time.Now().Add(timeout)
+	waiting := false
+
+	for {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			_, err = f.WriteString(owner)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, fmt.Errorf("failed to write lock file: %w", err)
+			}
+			return &StackLock{path: path, owner: owner}, nil
+		}
+
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to create lock file: %w", err)
+		}
+
+		holder, content, err := readStackLock(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue // released meanwhile
+		}
+
+		if isStaleLock(path, holder, err) {
+			// remove only the lock we judged stale, not the one just acquired by another process
+			if _, current, _ := readStackLock(path); current == content {
+				fmt.Printf("WARN: removing stale stack lock (%s)\n", renderStackLockHolder(holder, err))
+				os.Remove(path)
+			}
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w: held by %s, retry later or remove %s if it's not running", ErrStackLocked, renderStackLockHolder(holder, err), path)
+		}
+
+		if !waiting {
+			fmt.Printf("Waiting for stack lock held by %s ...\n", renderStackLockHolder(holder, err))
+			waiting = true
+		}
+
+		time.Sleep(stackLockInterval)
+	}
+}
+
+func renderStackLockHolder(holder stackLockHolder, err error) string {
+	if err != nil {
+		return "unknown process"
+	}
+	return holder.String()
+}
+
+// Unlock removes lock file, unless it was removed or taken over.
+func (l *StackLock) Unlock() error {
+	_, content, err := readStackLock(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if content != l.owner {
+		return nil
+	}
+
+	if err := os.Remove(l.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
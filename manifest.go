@@ -0,0 +1,219 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jaymecd/localtest/internal/release"
+	"github.com/spf13/cobra"
+)
+
+var ErrManifestSignature = errors.New("manifest signature is invalid")
+var ErrManifestUnsigned = errors.New("manifest is unsigned")
+
+type ManifestFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Perm   string `json:"perm"`
+	Sha256 string `json:"sha256"`
+}
+
+// Manifest mirrors manifest.json written by internal/manifest generator.
+type Manifest struct {
+	Digest    string         `json:"digest"`
+	Signature string         `json:"signature"`
+	PublicKey string         `json:"public_key"`
+	Files     []ManifestFile `json:"files"`
+}
+
+func parseManifest(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func embeddedManifest() (*Manifest, error) {
+	m, err := parseManifest(stackManifestJSON)
+	if err != nil {
+		return nil, fmt.Errorf("embedded manifest is invalid: %w", err)
+	}
+	return m, nil
+}
+
+func loadManifest(file string) (*Manifest, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := parseManifest(data)
+	if err != nil {
+		return nil, fmt.Errorf("manifest %q is invalid: %w", file, err)
+	}
+	return m, nil
+}
+
+// computeDigest must stay in sync with internal/manifest generator.
+func (m *Manifest) computeDigest() string {
+	h := sha256.New()
+	for _, file := range m.Files {
+		fmt.Fprintf(h, "%s\t%s\t%s\n", file.Path, file.Perm, file.Sha256)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// Verify checks that file list matches digest and digest is signed by trusted key.
+func (m *Manifest) Verify(trustedKey string) error {
+	if digest := m.computeDigest(); digest != m.Digest {
+		return fmt.Errorf("%w: digest %s does not match file list (%s)", ErrManifestSignature, m.Digest, digest)
+	}
+
+	if m.Signature == "" {
+		return fmt.Errorf("%w: development build, digest %s", ErrManifestUnsigned, m.Digest)
+	}
+
+	if m.PublicKey != trustedKey {
+		return fmt.Errorf("%w: signed by untrusted key %q", ErrManifestSignature, m.PublicKey)
+	}
+
+	publicKey, err := base64.StdEncoding.DecodeString(trustedKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("%w: malformed public key", ErrManifestSignature)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(m.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: malformed signature", ErrManifestSignature)
+	}
+
+	if !ed25519.Verify(publicKey, []byte(m.Digest), signature) {
+		return fmt.Errorf("%w: digest %s", ErrManifestSignature, m.Digest)
+	}
+
+	return nil
+}
+
+// verifyEmbeddedManifest ensures embedded manifest is signed by release key
+// and describes embedded files. Unsigned development builds are only warned about.
+func verifyEmbeddedManifest() error {
+	m, err := embeddedManifest()
+	if err != nil {
+		return err
+	}
+
+	if err := m.Verify(release.PublicKey); err != nil {
+		if !errors.Is(err, ErrManifestUnsigned) || m.computeDigest() != m.Digest {
+			return fmt.Errorf("embedded %w", err)
+		}
+		fmt.Printf("WARN: embedded %v, stack files are not verified against release key.\n", err)
+	}
+
+	if m.Digest != stackManifestDigest || m.Signature != stackManifestSignature {
+		return fmt.Errorf("embedded %w: does not match binary", ErrManifestSignature)
+	}
+
+	if len(m.Files) != len(stackFilesMeta) {
+		return fmt.Errorf("embedded %w: lists %d file(s), binary has %d", ErrManifestSignature, len(m.Files), len(stackFilesMeta))
+	}
+
+	for i, file := range stackFilesMeta {
+		entry := m.Files[i]
+		if entry.Path != file.Path || entry.Sha256 != file.Sha256 || entry.Perm != fmt.Sprintf("0%o", file.Perm) {
+			return fmt.Errorf("embedded %w: %s does not match binary", ErrManifestSignature, file.Path)
+		}
+	}
+
+	return nil
+}
+
+// verifyStackAgainstManifest checks on-disk stack files against signed manifest.
+func verifyStackAgainstManifest(dest string, m *Manifest, verbose bool) error {
+	if err := m.Verify(release.PublicKey); err != nil {
+		return err
+	}
+
+	fmt.Printf("Verifying stack integrity in %q directory against manifest %s ...\n", dest, m.Digest)
+
+	failures := 0
+
+	for _, file := range m.Files {
+		dst := filepath.Join(dest, filepath.FromSlash(file.Path))
+
+		if !fileExists(dst) {
+			fmt.Printf("%s: MISSING\n", file.Path)
+			failures++
+			continue
+		}
+
+		valid, err := verifySHA256(dst, file.Sha256)
+		if err != nil {
+			return err
+		}
+
+		if !valid {
+			fmt.Printf("%s: FAILED\n", file.Path)
+			failures++
+		} else if verbose {
+			fmt.Printf("%s: OK\n", file.Path)
+		}
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("WARNING: %d file(s) did NOT match manifest", failures)
+	}
+
+	return nil
+}
+
+func renderDigest(digest string) string {
+	if digest == "" {
+		return "unknown (synced by older binary)"
+	}
+	if digest == stackManifestDigest {
+		return digest + " (matches binary)"
+	}
+	return digest
+}
+
+var cmdManifest = &cobra.Command{
+	Use:   "manifest",
+	Short: "Print manifest of stack files embedded into binary",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if digestOnly, _ := cmd.Flags().GetBool("digest"); digestOnly {
+			fmt.Println(stackManifestDigest)
+			return nil
+		}
+
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+			fmt.Print(string(stackManifestJSON))
+			return nil
+		}
+
+		m, err := embeddedManifest()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Digest:     %s\n", m.Digest)
+		if m.Signature == "" {
+			fmt.Printf("Signature:  unsigned (development build)\n\n")
+		} else {
+			fmt.Printf("Signature:  %s\n", m.Signature)
+			fmt.Printf("Public key: %s\n\n", m.PublicKey)
+		}
+		for _, f := range m.Files {
+			fmt.Printf("%s  %s %8d  %s\n", f.Sha256, f.Perm, f.Size, f.Path)
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+type StackImage struct {
+	Ref    string
+	Source string
+	Built  bool // built by docker compose, not pulled
+}
+
+type composeConfig struct {
+	Services map[string]struct {
+		Image string `json:"image"`
+		Build *struct {
+			Context    string            `json:"context"`
+			Dockerfile string            `json:"dockerfile"`
+			Args       map[string]string `json:"args"`
+		} `json:"build"`
+	} `json:"services"`
+}
+
+var dockerfileVarRe = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?:(:?[-+])([^}]*))?\}|\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+// expandDockerfileArgs substitutes $VAR, ${VAR}, ${VAR:-word} and ${VAR:+word} references.
+func expandDockerfileArgs(value string, args map[string]string) string {
+	return dockerfileVarRe.ReplaceAllStringFunc(value, func(ref string) string {
+		m := dockerfileVarRe.FindStringSubmatch(ref)
+
+		name, op, word := m[1], m[2], m[3]
+		if name == "" {
+			name = m[4]
+		}
+
+		v, set := args[name]
+
+		switch {
+		case op == ":-" && v == "", op == "-" && !set:
+			return word
+		case op == ":+" && v != "", op == "+" && set:
+			return word
+		case op == ":+", op == "+":
+			return ""
+		}
+
+		return v
+	})
+}
+
+// parseDockerfileBaseImages returns external images referenced by FROM
+// instructions, with global ARG defaults overridden by build args.
+func parseDockerfileBaseImages(path string, buildArgs map[string]string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	args := map[string]string{}
+	stages := map[string]bool{}
+	seenFrom := false
+
+	var images []string
+	var line string
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		text := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		if cut, ok := strings.CutSuffix(text, "\\"); ok {
+			line += cut + " "
+			continue
+		}
+		line, text = "", line+text
+
+		fields := strings.Fields(text)
+		if len(fields) < 2 {
+			continue
+		}
+
+		switch strings.ToUpper(fields[0]) {
+		case "ARG":
+			if seenFrom {
+				continue // stage scoped args do not affect FROM
+			}
+			name, value, _ := strings.Cut(fields[1], "=")
+			if v, ok := buildArgs[name]; ok {
+				value = v
+			}
+			args[name] = strings.Trim(value, `"'`)
+		case "FROM":
+			seenFrom = true
+
+			fields = fields[1:]
+			for len(fields) > 0 && strings.HasPrefix(fields[0], "--") {
+				fields = fields[1:]
+			}
+			if len(fields) == 0 {
+				continue
+			}
+
+			image := expandDockerfileArgs(fields[0], args)
+			if len(fields) >= 3 && strings.EqualFold(fields[1], "AS") {
+				stages[strings.ToLower(fields[2])] = true
+			}
+
+			if image == "scratch" || stages[strings.ToLower(image)] {
+				continue
+			}
+
+			images = append(images, image)
+		}
+	}
+
+	return images, scanner.Err()
+}
+
+// prefetchImages works out every image the stack needs: image fields of
+// compose services and FROM lines of service Dockerfiles.
+func prefetchImages() ([]StackImage, error) {
+	out, err := dockerComposeOutput("config", "--format", "json")
+	if err != nil {
+		return nil, fmt.Errorf("docker compose config failed: %w", err)
+	}
+
+	var config composeConfig
+	if err := json.Unmarshal(out, &config); err != nil {
+		return nil, fmt.Errorf("unexpected docker compose config output: %w", err)
+	}
+
+	names := make([]string, 0, len(config.Services))
+	for name := range config.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var images []StackImage
+	seen := map[string]bool{}
+
+	add := func(img StackImage) {
+		if !seen[img.Ref] {
+			seen[img.Ref] = true
+			images = append(images, img)
+		}
+	}
+
+	for _, name := range names {
+		svc := config.Services[name]
+
+		if svc.Build == nil {
+			add(StackImage{Ref: svc.Image, Source: "service " + name})
+			continue
+		}
+
+		dockerfile := svc.Build.Dockerfile
+		if dockerfile == "" {
+			dockerfile = "Dockerfile"
+		}
+		if !filepath.IsAbs(dockerfile) {
+			dockerfile = filepath.Join(svc.Build.Context, dockerfile)
+		}
+
+		bases, err := parseDockerfileBaseImages(dockerfile, svc.Build.Args)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", name, err)
+		}
+
+		source := dockerfile
+		if rel, err := filepath.Rel(stackDir(), dockerfile); err == nil {
+			source = rel
+		}
+
+		for _, base := range bases {
+			add(StackImage{Ref: base, Source: source})
+		}
+
+		ref := svc.Image
+		if ref == "" {
+			ref = stackImage(name)
+		}
+		add(StackImage{Ref: ref, Source: "service " + name, Built: true})
+	}
+
+	return images, nil
+}
+
+func imageExists(ref string) bool {
+	return exec.Command("docker", "image", "inspect", "--format", "{{.Id}}", ref).Run() == nil
+}
+
+func pullImage(ref string) error {
+	cmd := exec.Command("docker", "pull", ref)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("docker pull %s failed: %w", ref, err)
+	}
+
+	return nil
+}
+
+var cmdPrefetch = &cobra.Command{
+	Use:   "prefetch",
+	Short: "Pull and build all stack images ahead of time",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		checkOnly, _ := cmd.Flags().GetBool("check")
+
+		lock, err := lockStack(stackDir(), stackLockTimeout)
+		if err != nil {
+			return err
+		}
+		defer lock.Unlock()
+
+		if _, err := syncStack(false); err != nil {
+			return err
+		}
+
+		images, err := prefetchImages()
+		if err != nil {
+			return err
+		}
+
+		var missing []StackImage
+
+		for _, img := range images {
+			status := "OK"
+			if !imageExists(img.Ref) {
+				status = "MISSING"
+				missing = append(missing, img)
+			}
+			fmt.Printf("%-8s %-50s %s\n", status, img.Ref, img.Source)
+		}
+
+		if checkOnly {
+			if len(missing) > 0 {
+				return fmt.Errorf("%d image(s) missing locally", len(missing))
+			}
+			return nil
+		}
+
+		if len(missing) == 0 {
+			fmt.Printf("\nAll images are available locally.\n")
+			return nil
+		}
+
+		if !HasInternetConnection() {
+			return fmt.Errorf("running in OFFLINE mode, %d image(s) could not be fetched", len(missing))
+		}
+
+		built := false
+		for _, img := range missing {
+			if img.Built {
+				built = true
+				continue
+			}
+			if err := pullImage(img.Ref); err != nil {
+				return err
+			}
+		}
+
+		if built {
+			if err := runDockerCompose("build"); err != nil {
+				return err
+			}
+		}
+
+		fmt.Printf("\nPrefetch DONE\n")
+
+		return nil
+	},
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+type PortCheck struct {
+	IP    string
+	Port  int
+	Proto string // tcp, udp
+	Usage string
+}
+
+func (c PortCheck) String() string {
+	return fmt.Sprintf("%s/%s", net.JoinHostPort(c.IP, strconv.Itoa(c.Port)), c.Proto)
+}
+
+type PortConflict struct {
+	Check PortCheck
+	Owner string
+	Hints []string
+}
+
+type procSocket struct {
+	IP    net.IP
+	Port  int
+	Inode string
+}
+
+// stackPortChecks lists host ports to be published by the stack.
+func stackPortChecks() ([]PortCheck, error) {
+	var ips []string
+
+	ipv4, _ := settingValue("DOCKER_DEFAULT_IP")
+	ips = append(ips, ipv4)
+
+	if ipv6, _ := settingValue("DOCKER_DEFAULT_IPV6"); ipv6 != "" {
+		ips = append(ips, ipv6)
+	}
+
+	entrypoints, err := effectiveTCPEntrypoints()
+	if err != nil {
+		return nil, err
+	}
+
+	var checks []PortCheck
+	for _, ip := range ips {
+		checks = append(checks,
+			PortCheck{IP: ip, Port: 53, Proto: "udp", Usage: "DNS"},
+			PortCheck{IP: ip, Port: 53, Proto: "tcp", Usage: "DNS"},
+			PortCheck{IP: ip, Port: 80, Proto: "tcp", Usage: "HTTP"},
+			PortCheck{IP: ip, Port: 443, Proto: "tcp", Usage: "HTTPS"},
+		)
+		for _, ep := range entrypoints {
+			checks = append(checks, PortCheck{IP: ip, Port: ep.Port, Proto: "tcp", Usage: ep.Name + " entrypoint"})
+		}
+	}
+
+	return checks, nil
+}
+
+func isWildcardIP(ip string) bool {
+	parsed := net.ParseIP(strings.Trim(ip, "[]"))
+	return ip == "" || (parsed != nil && parsed.IsUnspecified())
+}
+
+// portMatches reports whether listener address collides with requested bind IP.
+func portMatches(check PortCheck, ip string, port int, proto string) bool {
+	if check.Port != port || check.Proto != proto {
+		return false
+	}
+	if isWildcardIP(ip) {
+		return true
+	}
+	return net.ParseIP(strings.Trim(ip, "[]")).Equal(net.ParseIP(check.IP))
+}
+
+// parseDockerPorts parses 'docker ps' ports column, eg. "0.0.0.0:80->80/tcp, :::80->80/tcp".
+func parseDockerPorts(column string) []PortCheck {
+	var ports []PortCheck
+
+	for _, mapping := range strings.Split(column, ", ") {
+		published, target, ok := strings.Cut(mapping, "->")
+		if !ok {
+			continue
+		}
+
+		_, proto, _ := strings.Cut(target, "/")
+
+		idx := strings.LastIndex(published, ":")
+		if idx < 0 {
+			continue
+		}
+
+		port, err := strconv.Atoi(published[idx+1:])
+		if err != nil {
+			continue // port ranges are not published by the stack
+		}
+
+		ports = append(ports, PortCheck{IP: published[:idx], Port: port, Proto: proto})
+	}
+
+	return ports
+}
+
+// containerPortOwners maps conflicting checks to containers outside of the stack.
+// Checks published by the stack itself are reported as owned by "".
+func containerPortOwners(checks []PortCheck) (map[PortCheck]string, error) {
+	out, err := exec.Command("docker", "ps", "--format",
+		`{{.Names}}\t{{.Label "com.docker.compose.project"}}\t{{.Ports}}`).Output()
+	if err != nil {
+		return nil, fmt.Errorf("docker ps failed: %w", err)
+	}
+
+	owners := map[PortCheck]string{}
+
+	scanner := bufio.NewScanner(strings.NewReader(string(out)))
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+
+		name, project := fields[0], fields[1]
+
+		for _, published := range parseDockerPorts(fields[2]) {
+			for _, check := range checks {
+				if !portMatches(check, published.IP, published.Port, published.Proto) {
+					continue
+				}
+				if project == appName {
+					owners[check] = ""
+				} else {
+					owners[check] = fmt.Sprintf("container %q (%s)", name, published)
+				}
+			}
+		}
+	}
+
+	return owners, scanner.Err()
+}
+
+// parseProcNetAddr decodes "0100007F:0035" as written by kernel in host byte order.
+func parseProcNetAddr(value string) (net.IP, int, error) {
+	addrHex, portHex, ok := strings.Cut(value, ":")
+	if !ok {
+		return nil, 0, fmt.Errorf("invalid address %q", value)
+	}
+
+	raw, err := hex.DecodeString(addrHex)
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return nil, 0, fmt.Errorf("invalid address %q", value)
+	}
+
+	ip := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		binary.NativeEndian.PutUint32(ip[i:i+4], binary.BigEndian.Uint32(raw[i:i+4]))
+	}
+
+	port, err := strconv.ParseUint(portHex, 16, 16)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid port %q", value)
+	}
+
+	return ip, int(port), nil
+}
+
+// procListeners returns listening TCP or bound UDP sockets from /proc/net/*.
+func procListeners(proto string) ([]procSocket, error) {
+	// LISTEN for TCP, CLOSE (unconnected) for UDP
+	state := "0A"
+	if proto == "udp" {
+		state = "07"
+	}
+
+	var sockets []procSocket
+
+	for _, name := range []string{proto, proto + "6"} {
+		f, err := os.Open(filepath.Join("/proc/net", name))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) && name != proto {
+				continue // IPv6 disabled
+			}
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(f)
+		scanner.Scan() // header
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 10 || fields[3] != state {
+				continue
+			}
+
+			ip, port, err := parseProcNetAddr(fields[1])
+			if err != nil {
+				continue
+			}
+
+			sockets = append(sockets, procSocket{IP: ip, Port: port, Inode: fields[9]})
+		}
+		f.Close()
+	}
+
+	return sockets, nil
+}
+
+// procSocketOwner finds process holding socket inode by scanning /proc/<pid>/fd.
+func procSocketOwner(inode string) string {
+	target := "socket:[" + inode + "]"
+
+	pids, _ := filepath.Glob("/proc/[0-9]*")
+	for _, dir := range pids {
+		fds, err := os.ReadDir(filepath.Join(dir, "fd"))
+		if err != nil {
+			continue
+		}
+
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(dir, "fd", fd.Name()))
+			if err != nil || link != target {
+				continue
+			}
+
+			pid := filepath.Base(dir)
+			comm, _ := os.ReadFile(filepath.Join(dir, "comm"))
+			cmdline, _ := os.ReadFile(filepath.Join(dir, "cmdline"))
+
+			args := strings.TrimSpace(strings.ReplaceAll(string(cmdline), "\x00", " "))
+			return fmt.Sprintf("process %q (PID %s: %s)", strings.TrimSpace(string(comm)), pid, args)
+		}
+	}
+
+	return "unknown process (run as root to see other users' processes)"
+}
+
+// probePortInUse tries to bind the port, as fallback without /proc.
+func probePortInUse(check PortCheck) bool {
+	addr := net.JoinHostPort(check.IP, strconv.Itoa(check.Port))
+
+	var err error
+	if check.Proto == "udp" {
+		var conn net.PacketConn
+		if conn, err = net.ListenPacket("udp", addr); err == nil {
+			conn.Close()
+		}
+	} else {
+		var ln net.Listener
+		if ln, err = net.Listen("tcp", addr); err == nil {
+			ln.Close()
+		}
+	}
+
+	return errors.Is(err, syscall.EADDRINUSE)
+}
+
+func portConflictHints(check PortCheck, owner string) []string {
+	var hints []string
+
+	if strings.Contains(owner, "systemd-resolve") {
+		hints = append(hints, "remove DNSStubListenerExtra for this IP from systemd-resolved config")
+	}
+
+	if strings.HasPrefix(owner, "container") {
+		hints = append(hints, "stop the container or publish its port on another IP")
+	} else {
+		hints = append(hints, "stop the process or restrict it to listen on another IP")
+	}
+
+	hints = append(hints, fmt.Sprintf("bind the stack to another IP: %s settings set DOCKER_DEFAULT_IP=...", appName))
+
+	if check.Port != 53 && check.Port != 80 && check.Port != 443 {
+		hints = append(hints, fmt.Sprintf("move %s to another port: %s settings set TCP_ENTRYPOINTS=...", check.Usage, appName))
+	}
+
+	return hints
+}
+
+// preflightPorts detects host ports required by the stack, that are already taken.
+func preflightPorts() ([]PortConflict, error) {
+	checks, err := stackPortChecks()
+	if err != nil {
+		return nil, err
+	}
+
+	owners, err := containerPortOwners(checks)
+	if err != nil {
+		return nil, err
+	}
+
+	listeners := map[string][]procSocket{}
+	for _, proto := range []string{"tcp", "udp"} {
+		if listeners[proto], err = procListeners(proto); err != nil {
+			listeners = nil
+			break
+		}
+	}
+
+	var conflicts []PortConflict
+
+	for _, check := range checks {
+		owner, found := owners[check]
+		if found && owner == "" {
+			continue // published by the stack itself
+		}
+
+		if !found && listeners != nil {
+			for _, sock := range listeners[check.Proto] {
+				if portMatches(check, sock.IP.String(), sock.Port, check.Proto) {
+					owner = procSocketOwner(sock.Inode)
+					found = !strings.Contains(owner, "docker-proxy")
+					break
+				}
+			}
+		}
+
+		if !found && listeners == nil && probePortInUse(check) {
+			owner, found = "unknown owner", true
+		}
+
+		if found {
+			conflicts = append(conflicts, PortConflict{
+				Check: check,
+				Owner: owner,
+				Hints: portConflictHints(check, owner),
+			})
+		}
+	}
+
+	return conflicts, nil
+}
+
+func checkPortConflicts() error {
+	conflicts, err := preflightPorts()
+	if err != nil {
+		fmt.Printf("WARN: port pre-flight check skipped (%v)\n", err)
+		return nil
+	}
+
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	for _, c := range conflicts {
+		fmt.Printf("ERROR: %s port %s is taken by %s\n", c.Check.Usage, c.Check, c.Owner)
+		for _, hint := range c.Hints {
+			fmt.Printf("  - %s\n", hint)
+		}
+	}
+
+	return fmt.Errorf("%d port conflict(s) detected", len(conflicts))
+}
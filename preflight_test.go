@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestParseProcNetAddr(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		ip      string
+		port    int
+		wantErr bool
+	}{
+		{name: "ipv4 loopback", value: "0100007F:0035", ip: "127.0.0.1", port: 53},
+		{name: "ipv4 wildcard", value: "00000000:01BB", ip: "0.0.0.0", port: 443},
+		{name: "ipv4 docker bridge", value: "010011AC:0050", ip: "172.17.0.1", port: 80},
+		{name: "ipv6 wildcard", value: "00000000000000000000000000000000:0050", ip: "::", port: 80},
+		{name: "ipv6 loopback", value: "00000000000000000000000001000000:1538", ip: "::1", port: 5432},
+		{name: "ipv6 mapped ipv4", value: "0000000000000000FFFF00000100007F:0035", ip: "127.0.0.1", port: 53},
+		{name: "ipv6 ula", value: "0000FDFD000000000000000001000000:01BB", ip: "fdfd::1", port: 443},
+		{name: "missing port", value: "0100007F", wantErr: true},
+		{name: "invalid hex", value: "ZZ00007F:0035", wantErr: true},
+		{name: "invalid length", value: "00007F:0035", wantErr: true},
+		{name: "invalid port", value: "0100007F:XYZ", wantErr: true},
+		{name: "port overflow", value: "0100007F:10000", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ip, port, err := parseProcNetAddr(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseProcNetAddr(%q) = %s, %d, want error", tt.value, ip, port)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseProcNetAddr(%q) error: %v", tt.value, err)
+			}
+			if !ip.Equal(net.ParseIP(tt.ip)) || port != tt.port {
+				t.Errorf("parseProcNetAddr(%q) = %s, %d, want %s, %d", tt.value, ip, port, tt.ip, tt.port)
+			}
+		})
+	}
+}
+
+func TestParseDockerPorts(t *testing.T) {
+	tests := []struct {
+		name   string
+		column string
+		want   []PortCheck
+	}{
+		{name: "empty", column: "", want: nil},
+		{name: "exposed only", column: "80/tcp", want: nil},
+		{
+			name:   "ipv4 tcp and udp",
+			column: "172.17.0.1:53->53/udp, 172.17.0.1:53->53/tcp",
+			want: []PortCheck{
+				{IP: "172.17.0.1", Port: 53, Proto: "udp"},
+				{IP: "172.17.0.1", Port: 53, Proto: "tcp"},
+			},
+		},
+		{
+			name:   "wildcard ipv4 and ipv6",
+			column: "0.0.0.0:8080->80/tcp, [::]:8080->80/tcp",
+			want: []PortCheck{
+				{IP: "0.0.0.0", Port: 8080, Proto: "tcp"},
+				{IP: "[::]", Port: 8080, Proto: "tcp"},
+			},
+		},
+		{
+			name:   "legacy ipv6 wildcard",
+			column: ":::80->80/tcp",
+			want:   []PortCheck{{IP: "::", Port: 80, Proto: "tcp"}},
+		},
+		{
+			name:   "ipv6 address",
+			column: "[fd00:7e57::1]:443->443/tcp",
+			want:   []PortCheck{{IP: "[fd00:7e57::1]", Port: 443, Proto: "tcp"}},
+		},
+		{
+			name:   "port range skipped",
+			column: "0.0.0.0:7000-7001->7000-7001/tcp, 127.0.0.1:6379->6379/tcp",
+			want:   []PortCheck{{IP: "127.0.0.1", Port: 6379, Proto: "tcp"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseDockerPorts(tt.column)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseDockerPorts(%q) = %+v, want %+v", tt.column, got, tt.want)
+			}
+		})
+	}
+}
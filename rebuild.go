@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// kinds of rebuild reasons recorded in .rebuild marker
+const (
+	RebuildContext = "context"
+	RebuildArgs    = "args"
+	RebuildCA      = "ca"
+	RebuildUnknown = "unknown"
+)
+
+// RebuildReason is stored as tab separated line: kind, service, detail.
+type RebuildReason struct {
+	Kind    string
+	Service string
+	Detail  string
+}
+
+func (r RebuildReason) String() string {
+	if r.Service == "" {
+		return fmt.Sprintf("%s: %s", r.Kind, r.Detail)
+	}
+	return fmt.Sprintf("%s (%s): %s", r.Kind, r.Service, r.Detail)
+}
+
+func loadRebuildReasons(path string) ([]RebuildReason, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var reasons []RebuildReason
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		fields := strings.SplitN(line, "\t", 3)
+		for len(fields) < 3 {
+			fields = append(fields, "")
+		}
+
+		reasons = append(reasons, RebuildReason{Kind: fields[0], Service: fields[1], Detail: fields[2]})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// empty marker written by older versions
+	if len(reasons) == 0 {
+		reasons = append(reasons, RebuildReason{Kind: RebuildUnknown, Detail: "reason was not recorded"})
+	}
+
+	return reasons, nil
+}
+
+func saveRebuildReasons(path string, reasons []RebuildReason) error {
+	var b strings.Builder
+	for _, r := range reasons {
+		fmt.Fprintf(&b, "%s\t%s\t%s\n", r.Kind, r.Service, strings.ReplaceAll(r.Detail, "\n", " "))
+	}
+
+	return os.WriteFile(path, []byte(b.String()), 0600)
+}
+
+// mergeRebuildReasons appends reasons, replacing ones of the same kind and service.
+func mergeRebuildReasons(reasons []RebuildReason, add ...RebuildReason) []RebuildReason {
+	for _, r := range add {
+		replaced := false
+		for i := range reasons {
+			if reasons[i].Kind == r.Kind && reasons[i].Service == r.Service {
+				reasons[i] = r
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			reasons = append(reasons, r)
+		}
+	}
+
+	return reasons
+}
+
+// markStackRebuild flags existing stack to be rebuilt on next 'up'.
+func markStackRebuild(add ...RebuildReason) error {
+	dest := stackDir()
+
+	if !fileExists(filepath.Join(dest, stackInfoFile)) {
+		return nil // fresh stack is built anyway
+	}
+
+	path := filepath.Join(dest, stackRebuildFile)
+
+	reasons, err := loadRebuildReasons(path)
+	if err != nil {
+		return err
+	}
+
+	return saveRebuildReasons(path, mergeRebuildReasons(reasons, add...))
+}
+
+// rebuildTargets returns services to be built, or all, if any reason is
+// not bound to a service.
+func rebuildTargets(dest string, reasons []RebuildReason) ([]string, bool) {
+	var services []string
+	seen := map[string]bool{}
+
+	for _, r := range reasons {
+		if r.Service == "" {
+			return nil, true
+		}
+		if seen[r.Service] {
+			continue
+		}
+		seen[r.Service] = true
+
+		// image only services, eg. whoami, are recreated without build
+		if dirExists(filepath.Join(dest, "services", r.Service)) {
+			services = append(services, r.Service)
+		}
+	}
+
+	return services, false
+}
+
+// composeBuildArgs extracts services.<name>.build.args of compose file,
+// which is maintained in block style with 2 spaces indentation.
+func composeBuildArgs(data []byte) map[string]map[string]string {
+	args := map[string]map[string]string{}
+
+	var section, service string
+	var inBuild, inArgs bool
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		key, value, _ := strings.Cut(trimmed, ":")
+		value, _, _ = strings.Cut(value, " #")
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+
+		switch indent := len(line) - len(strings.TrimLeft(line, " ")); {
+		case indent == 0:
+			section, service, inBuild, inArgs = key, "", false, false
+		case indent == 2 && section == "services":
+			service, inBuild, inArgs = key, false, false
+		case indent == 4:
+			inBuild, inArgs = key == "build", false
+		case indent == 6 && inBuild:
+			inArgs = key == "args"
+		case indent == 8 && inArgs && service != "":
+			if args[service] == nil {
+				args[service] = map[string]string{}
+			}
+			args[service][key] = expandComposeValue(value)
+		}
+	}
+
+	return args
+}
+
+var composeVarRe = regexp.MustCompile(`\$\{(\w+):-([^}]*)\}`)
+
+// expandComposeValue resolves ${KEY:-default} using environment and settings,
+// falling back to default given in compose file.
+func expandComposeValue(value string) string {
+	return composeVarRe.ReplaceAllStringFunc(value, func(m string) string {
+		match := composeVarRe.FindStringSubmatch(m)
+		if v, ok := os.LookupEnv(match[1]); ok {
+			return v
+		}
+		if values, err := loadSettings(); err == nil {
+			if v, ok := values[match[1]]; ok {
+				return v
+			}
+		}
+		return match[2]
+	})
+}
+
+// buildArgsChanges compares effective build args of services present in both
+// compose files, eg. default version bumped by binary upgrade.
+func buildArgsChanges(oldCompose, newCompose []byte) []RebuildReason {
+	oldArgs := composeBuildArgs(oldCompose)
+	newArgs := composeBuildArgs(newCompose)
+
+	services := make([]string, 0, len(newArgs))
+	for service := range newArgs {
+		if _, ok := oldArgs[service]; ok {
+			services = append(services, service)
+		}
+	}
+	sort.Strings(services)
+
+	var reasons []RebuildReason
+
+	for _, service := range services {
+		keys := make([]string, 0, len(newArgs[service]))
+		for key := range newArgs[service] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var changed []string
+		for _, key := range keys {
+			if before, after := oldArgs[service][key], newArgs[service][key]; before != after {
+				changed = append(changed, fmt.Sprintf("%s %s -> %s", key, before, after))
+			}
+		}
+
+		if len(changed) > 0 {
+			reasons = append(reasons, RebuildReason{
+				Kind:    RebuildArgs,
+				Service: service,
+				Detail:  strings.Join(changed, ", "),
+			})
+		}
+	}
+
+	return reasons
+}
+
+func printRebuildReasons(reasons []RebuildReason) {
+	for _, r := range reasons {
+		fmt.Printf("  - %s\n", r)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const stackNetwork = "localtest"
+const stackCertsVolume = "localtest_certs"
+const stackCertsMount = "/certs"
+
+// envvars understood by common runtimes to locate CA bundle (see SSL_TESTING.md)
+var stackCAEnv = []string{
+	"SSL_CERT_FILE",
+	"CURL_CA_BUNDLE",
+	"REQUESTS_CA_BUNDLE",
+	"HTTPLIB2_CA_CERTS",
+	"NODE_EXTRA_CA_CERTS",
+	"GIT_SSL_CAINFO",
+	"AWS_CA_BUNDLE",
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+func stackNetworkExists() bool {
+	return exec.Command("docker", "network", "inspect", stackNetwork).Run() == nil
+}
+
+func runThrowawayContainer(image string, command []string) error {
+	if !stackNetworkExists() {
+		return fmt.Errorf("%w: network %q not found, run 'up' first", ErrStackNotExist, stackNetwork)
+	}
+
+	args := []string{"run", "--rm", "-i"}
+
+	if isTerminal(os.Stdin) && isTerminal(os.Stdout) {
+		args = append(args, "-t")
+	}
+
+	args = append(args,
+		"--network", stackNetwork,
+		"-v", stackCertsVolume+":"+stackCertsMount+":ro",
+	)
+
+	for _, name := range stackCAEnv {
+		args = append(args, "-e", name+"="+stackCertsMount+"/ca-bundle.pem")
+	}
+
+	args = append(args, image)
+	args = append(args, command...)
+
+	cmd := exec.Command("docker", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+
+	preview_cmd := strings.Join(append([]string{"docker", "run", "...", image}, command...), " ")
+
+	fmt.Fprintf(os.Stderr, "Proxying call to %q ...\n", preview_cmd)
+
+	return cmd.Run()
+}
+
+var cmdRun = &cobra.Command{
+	Use:   "run [--image IMAGE] -- [COMMAND] [ARG...]",
+	Short: "Run a throwaway container attached to the stack network",
+	Example: fmt.Sprintf(`  %[1]s run -- sh -ec 'apk add -q curl; curl -LisS https://whoami.local.test'
+  %[1]s run --image python:3.12-alpine -- python3`, appName),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		image, _ := cmd.Flags().GetString("image")
+
+		err := runThrowawayContainer(image, args)
+
+		// forward exit code of the container as is
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
+
+		return err
+	},
+}
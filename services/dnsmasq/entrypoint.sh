@@ -13,4 +13,26 @@ fi
 
 set -e
 
-exec "$@"
+# dnsmasq does not re-read conf-dir on SIGHUP, so restart it instead
+# to apply records managed by 'localtest dns' command
+RELOAD=0
+trap 'RELOAD=1; kill -TERM "${PID}" 2>/dev/null || :' HUP
+trap 'RELOAD=0; kill -TERM "${PID}" 2>/dev/null || :' INT TERM
+
+while :; do
+    "$@" &
+    PID=$!
+
+    # wait is interrupted by trapped signals, so repeat until dnsmasq exits
+    STATUS=0
+    while kill -0 "${PID}" 2>/dev/null; do
+        wait "${PID}" || STATUS=$?
+    done
+
+    if [ "${RELOAD}" -eq 0 ]; then
+        exit "${STATUS}"
+    fi
+
+    echo "Reloading dnsmasq config ..."
+    RELOAD=0
+done
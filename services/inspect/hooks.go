@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var channelPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+type Hook struct {
+	ID         int64               `json:"id"`
+	Channel    string              `json:"channel"`
+	Time       time.Time           `json:"time"`
+	Method     string              `json:"method"`
+	Host       string              `json:"host"`
+	Path       string              `json:"path"` // after /c/<name>, eg. /stripe
+	Query      string              `json:"query,omitempty"`
+	RemoteAddr string              `json:"remote_addr"`
+	Headers    map[string][]string `json:"headers"`
+	Body       []byte              `json:"body"` // base64 in JSON, replayed as is
+	Size       int64               `json:"size"`
+	Truncated  bool                `json:"truncated,omitempty"`
+}
+
+type Channel struct {
+	Name     string    `json:"name"`
+	Count    int       `json:"count"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// recordHook handles /c/<name>[/path], accepting any method.
+func recordHook(store *Store[Hook], maxBody int64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.TrimPrefix(r.URL.Path, "/c/")
+		name, path, _ := strings.Cut(rest, "/")
+
+		if !channelPattern.MatchString(name) {
+			http.Error(w, "invalid channel name, expected /c/<name> with [A-Za-z0-9._-] characters", http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBody+1))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// count the rest, without keeping it
+		discarded, _ := io.Copy(io.Discard, r.Body)
+
+		hook := Hook{
+			Channel:    name,
+			Time:       time.Now().UTC(),
+			Method:     r.Method,
+			Host:       r.Host,
+			Path:       "/" + path,
+			Query:      r.URL.RawQuery,
+			RemoteAddr: r.RemoteAddr,
+			Headers:    r.Header,
+			Body:       body,
+			Size:       int64(len(body)) + discarded,
+		}
+
+		if int64(len(body)) > maxBody {
+			hook.Body = body[:maxBody]
+			hook.Truncated = true
+		}
+
+		hook = store.Add(name, func(id int64) Hook {
+			hook.ID = id
+			return hook
+		})
+
+		log.Printf("Recorded #%d %s %s%s (%d bytes)", hook.ID, hook.Method, r.Host, r.URL.Path, hook.Size)
+
+		writeJSON(w, http.StatusOK, map[string]any{"id": hook.ID, "channel": hook.Channel})
+	}
+}
+
+func hooksAPI(store *Store[Hook]) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/hooks"), "/")
+		channel := r.URL.Query().Get("channel")
+
+		switch {
+		case rest == "" && r.Method == http.MethodGet:
+			writeJSON(w, http.StatusOK, store.List(channel, 0))
+		case rest == "" && r.Method == http.MethodDelete:
+			store.Clear(channel)
+			w.WriteHeader(http.StatusNoContent)
+		case rest != "" && r.Method == http.MethodGet:
+			id, err := strconv.ParseInt(rest, 10, 64)
+			if err != nil {
+				http.Error(w, "invalid hook id", http.StatusBadRequest)
+				return
+			}
+			hook, ok := store.Get(id)
+			if !ok {
+				http.Error(w, "hook not found", http.StatusNotFound)
+				return
+			}
+			writeJSON(w, http.StatusOK, hook)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// handleHooks registers webhook recorder on mux for requests to host.
+func handleHooks(mux *http.ServeMux, host string, store *Store[Hook], maxBody int64) {
+	mux.HandleFunc(host+"/c/", recordHook(store, maxBody))
+	mux.HandleFunc(host+"/api/hooks", hooksAPI(store))
+	mux.HandleFunc(host+"/api/hooks/", hooksAPI(store))
+	mux.HandleFunc(host+"/api/channels", func(w http.ResponseWriter, r *http.Request) {
+		channels := []Channel{}
+		for _, b := range store.Buckets() {
+			channels = append(channels, Channel{Name: b.Name, Count: b.Count, LastSeen: b.Last.Time})
+		}
+		writeJSON(w, http.StatusOK, channels)
+	})
+	mux.HandleFunc(host+"/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, "Send webhooks to https://%s/c/<name>, inspect them using 'localtest hooks list'.\n", r.Host)
+	})
+}
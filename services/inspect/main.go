@@ -0,0 +1,262 @@
+// Command inspect captures any request routed to it and shows them in web UI.
+// Requests to hooks host are recorded as webhooks per named channel instead,
+// so they could be replayed.
+//
+// Built with standard library only, via 'go build *.go'.
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const prefix = "/_inspect/"
+
+type CapturedRequest struct {
+	ID         int64               `json:"id"`
+	Time       time.Time           `json:"time"`
+	Method     string              `json:"method"`
+	Host       string              `json:"host"`
+	Path       string              `json:"path"`
+	Query      string              `json:"query,omitempty"`
+	Proto      string              `json:"proto"`
+	RemoteAddr string              `json:"remote_addr"`
+	Headers    map[string][]string `json:"headers"`
+	Body       string              `json:"body,omitempty"`
+	BodyBase64 string              `json:"body_base64,omitempty"` // set instead of body, if it's binary
+	Size       int64               `json:"size"`
+	Truncated  bool                `json:"truncated,omitempty"`
+}
+
+func capture(store *Store[CapturedRequest], maxBody int64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBody+1))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// count the rest, without keeping it
+		rest, _ := io.Copy(io.Discard, r.Body)
+
+		req := CapturedRequest{
+			Time:       time.Now().UTC(),
+			Method:     r.Method,
+			Host:       r.Host,
+			Path:       r.URL.Path,
+			Query:      r.URL.RawQuery,
+			Proto:      r.Proto,
+			RemoteAddr: r.RemoteAddr,
+			Headers:    r.Header,
+			Size:       int64(len(body)) + rest,
+		}
+
+		if int64(len(body)) > maxBody {
+			body = body[:maxBody]
+			req.Truncated = true
+		}
+
+		if utf8.Valid(body) {
+			req.Body = string(body)
+		} else {
+			req.BodyBase64 = base64.StdEncoding.EncodeToString(body)
+		}
+
+		req = store.Add("", func(id int64) CapturedRequest {
+			req.ID = id
+			return req
+		})
+
+		writeJSON(w, http.StatusOK, map[string]any{"captured": req.ID})
+	}
+}
+
+func api(store *Store[CapturedRequest]) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.TrimPrefix(r.URL.Path, prefix+"api/requests")
+		rest = strings.Trim(rest, "/")
+
+		switch {
+		case rest == "" && r.Method == http.MethodGet:
+			since, _ := strconv.ParseInt(r.URL.Query().Get("since"), 10, 64)
+			writeJSON(w, http.StatusOK, store.List("", since))
+		case rest == "" && r.Method == http.MethodDelete:
+			store.Clear("")
+			w.WriteHeader(http.StatusNoContent)
+		case rest != "" && r.Method == http.MethodGet:
+			id, err := strconv.ParseInt(rest, 10, 64)
+			if err != nil {
+				http.Error(w, "invalid request id", http.StatusBadRequest)
+				return
+			}
+			req, ok := store.Get(id)
+			if !ok {
+				http.Error(w, "request not found", http.StatusNotFound)
+				return
+			}
+			writeJSON(w, http.StatusOK, req)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// stream pushes captured requests as server-sent events.
+func stream(store *Store[CapturedRequest]) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		ch, cancel := store.Subscribe()
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		keepalive := time.NewTicker(15 * time.Second)
+		defer keepalive.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-keepalive.C:
+				fmt.Fprint(w, ": keepalive\n\n")
+			case req := <-ch:
+				data, _ := json.Marshal(req)
+				fmt.Fprintf(w, "id: %d\ndata: %s\n\n", req.ID, data)
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func main() {
+	listen := os.Getenv("INSPECT_LISTEN")
+	if listen == "" {
+		listen = ":8080"
+	}
+
+	hooksHost := os.Getenv("HOOKS_HOST")
+	if hooksHost == "" {
+		hooksHost = "hooks.local.test"
+	}
+
+	limit := envInt("INSPECT_LIMIT", 100)
+	maxBody := int64(envInt("INSPECT_MAX_BODY", 1024*1024))
+	hooksLimit := envInt("HOOKS_LIMIT", 100)
+	hooksMaxBody := int64(envInt("HOOKS_MAX_BODY", 1024*1024))
+
+	store := NewStore[CapturedRequest](limit)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(prefix+"health", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "OK")
+	})
+	mux.HandleFunc(prefix+"api/requests", api(store))
+	mux.HandleFunc(prefix+"api/requests/", api(store))
+	mux.HandleFunc(prefix+"api/stream", stream(store))
+	mux.HandleFunc(prefix, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != prefix {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, indexHTML)
+	})
+	mux.HandleFunc("/", capture(store, maxBody))
+
+	// host specific patterns take precedence over capturing ones above
+	handleHooks(mux, hooksHost, NewStore[Hook](hooksLimit), hooksMaxBody)
+
+	log.Printf("Listening on %s, keeping last %d requests (max body %d bytes) ...", listen, limit, maxBody)
+	log.Printf("Recording webhooks to %s, keeping last %d per channel (max body %d bytes) ...", hooksHost, hooksLimit, hooksMaxBody)
+
+	server := &http.Server{Addr: listen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	log.Fatal(server.ListenAndServe())
+}
+
+// indexHTML lives here, so service is built from single file.
+const indexHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>inspect.local.test</title>
+<style>
+  body { margin: 0; font: 13px/1.4 ui-monospace, Menlo, Consolas, monospace; display: flex; height: 100vh; }
+  #list { width: 40%; overflow-y: auto; border-right: 1px solid #ccc; }
+  #detail { flex: 1; overflow-y: auto; padding: 0 1em; }
+  .item { padding: .4em .6em; border-bottom: 1px solid #eee; cursor: pointer; white-space: nowrap; overflow: hidden; text-overflow: ellipsis; }
+  .item:hover, .item.active { background: #eef4ff; }
+  .method { font-weight: bold; display: inline-block; min-width: 4.5em; }
+  header { padding: .6em; border-bottom: 1px solid #ccc; display: flex; justify-content: space-between; }
+  pre { background: #f6f6f6; padding: .6em; white-space: pre-wrap; word-break: break-all; }
+  table { border-collapse: collapse; }
+  td { padding: 0 .6em .2em 0; vertical-align: top; }
+</style>
+</head>
+<body>
+<div id="list"><header><b>Captured requests</b><button id="clear">clear</button></header><div id="items"></div></div>
+<div id="detail"><p>Send any request to <code>https://inspect.local.test/...</code> or <code>https://*.inspect.local.test/...</code></p></div>
+<script>
+const items = document.getElementById("items");
+const detail = document.getElementById("detail");
+const requests = new Map();
+
+function esc(s) {
+  return String(s).replace(/[&<>"]/g, c => ({"&": "&amp;", "<": "&lt;", ">": "&gt;", '"': "&quot;"}[c]));
+}
+
+function show(req) {
+  document.querySelectorAll(".item.active").forEach(el => el.classList.remove("active"));
+  document.getElementById("req-" + req.id)?.classList.add("active");
+  const headers = Object.entries(req.headers || {}).sort()
+    .map(([k, v]) => "<tr><td><b>" + esc(k) + "</b></td><td>" + esc(v.join(", ")) + "</td></tr>").join("");
+  let body = req.body_base64 ? "(binary, base64)\n" + req.body_base64 : (req.body || "");
+  if (req.truncated) body += "\n... truncated, " + req.size + " bytes total";
+  detail.innerHTML =
+    "<h3>#" + req.id + " " + esc(req.method) + " " + esc(req.host + req.path + (req.query ? "?" + req.query : "")) + "</h3>" +
+    "<p>" + esc(req.time) + " from " + esc(req.remote_addr) + " via " + esc(req.proto) + ", " + req.size + " bytes</p>" +
+    "<h4>Headers</h4><table>" + headers + "</table>" +
+    "<h4>Body</h4><pre>" + esc(body) + "</pre>";
+}
+
+function add(req) {
+  requests.set(req.id, req);
+  const el = document.createElement("div");
+  el.className = "item";
+  el.id = "req-" + req.id;
+  el.innerHTML = "<span class=method>" + esc(req.method) + "</span> " + esc(req.host + req.path) +
+    " <small>" + new Date(req.time).toLocaleTimeString() + "</small>";
+  el.onclick = () => show(req);
+  items.prepend(el);
+}
+
+document.getElementById("clear").onclick = async () => {
+  await fetch("api/requests", {method: "DELETE"});
+  requests.clear();
+  items.innerHTML = "";
+};
+
+fetch("api/requests").then(r => r.json()).then(list => {
+  list.forEach(add);
+  new EventSource("api/stream").onmessage = ev => add(JSON.parse(ev.data));
+});
+</script>
+</body>
+</html>
+`
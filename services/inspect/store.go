@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+type entry[T any] struct {
+	id    int64
+	value T
+}
+
+// Bucket summarizes values kept under the same key, eg. webhook channel.
+type Bucket[T any] struct {
+	Name  string
+	Count int
+	Last  T
+}
+
+// Store keeps last values of each bucket, assigning them increasing IDs,
+// and notifies subscribers of new ones.
+type Store[T any] struct {
+	mu      sync.Mutex
+	limit   int
+	nextID  int64
+	buckets map[string][]entry[T]
+	waiters map[chan T]struct{}
+}
+
+func NewStore[T any](limit int) *Store[T] {
+	return &Store[T]{limit: limit, nextID: 1, buckets: map[string][]entry[T]{}, waiters: map[chan T]struct{}{}}
+}
+
+// Add stores value built with next ID, dropping oldest ones of bucket over limit.
+func (s *Store[T]) Add(bucket string, build func(id int64) T) T {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.nextID
+	s.nextID++
+
+	value := build(id)
+
+	list := append(s.buckets[bucket], entry[T]{id: id, value: value})
+	if len(list) > s.limit {
+		list = list[len(list)-s.limit:]
+	}
+	s.buckets[bucket] = list
+
+	for ch := range s.waiters {
+		select {
+		case ch <- value:
+		default: // slow subscriber, drop
+		}
+	}
+
+	return value
+}
+
+// List returns values of bucket, or of all buckets if empty, with ID
+// greater than since, oldest first.
+func (s *Store[T]) List(bucket string, since int64) []T {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var entries []entry[T]
+	for name, list := range s.buckets {
+		if bucket != "" && bucket != name {
+			continue
+		}
+		for _, e := range list {
+			if e.id > since {
+				entries = append(entries, e)
+			}
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].id < entries[j].id })
+
+	values := make([]T, 0, len(entries))
+	for _, e := range entries {
+		values = append(values, e.value)
+	}
+	return values
+}
+
+func (s *Store[T]) Get(id int64) (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, list := range s.buckets {
+		for _, e := range list {
+			if e.id == id {
+				return e.value, true
+			}
+		}
+	}
+
+	var zero T
+	return zero, false
+}
+
+// Buckets returns non-empty buckets, sorted by name.
+func (s *Store[T]) Buckets() []Bucket[T] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	buckets := []Bucket[T]{}
+	for name, list := range s.buckets {
+		if len(list) == 0 {
+			continue
+		}
+		buckets = append(buckets, Bucket[T]{Name: name, Count: len(list), Last: list[len(list)-1].value})
+	}
+
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Name < buckets[j].Name })
+
+	return buckets
+}
+
+// Clear drops values of bucket, or of all buckets if empty.
+func (s *Store[T]) Clear(bucket string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if bucket == "" {
+		s.buckets = map[string][]entry[T]{}
+		return
+	}
+	delete(s.buckets, bucket)
+}
+
+func (s *Store[T]) Subscribe() (chan T, func()) {
+	ch := make(chan T, 64)
+
+	s.mu.Lock()
+	s.waiters[ch] = struct{}{}
+	s.mu.Unlock()
+
+	return ch, func() {
+		s.mu.Lock()
+		delete(s.waiters, ch)
+		s.mu.Unlock()
+	}
+}
+
+func envInt(key string, fallback int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil && value > 0 {
+		return value
+	}
+	return fallback
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
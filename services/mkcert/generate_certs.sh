@@ -25,6 +25,7 @@ echo "Generating TLS certificates ..."
 [[ -n "${TLS_SANS_COMMON:-}" ]] || fatal "envvar TLS_SANS_COMMON is empty or missing"
 
 : "${TLS_SANS_EXTRA:=}"  # optional envvar
+: "${TLS_RENEW_DAYS:=30}"  # optional envvar
 
 # store result to requested_sans
 echo "${TLS_SANS_COMMON} ${TLS_SANS_EXTRA}" \
@@ -63,6 +64,11 @@ if [[ ! -s "${TLS_PATH_CERTS}/localtest.pem" ]]; then
 	# reset checksum
 	rm -f "${TLS_PATH_CERTS}/checksums.sha256"
 	sync
+elif ! openssl x509 -in "${TLS_PATH_CERTS}/localtest.pem" -noout -checkend "$(( TLS_RENEW_DAYS * 86400 ))" > /dev/null; then
+    echo "Leaf certificate expires within ${TLS_RENEW_DAYS} days, forcing renewal ..."
+    # reset checksum
+    rm -f "${TLS_PATH_CERTS}/checksums.sha256"
+    sync
 fi
 
 if [[ -s "${TLS_PATH_CERTS}/checksums.sha256" ]]; then
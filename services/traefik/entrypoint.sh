@@ -19,6 +19,20 @@ fi
 
 set -e
 
+# render TCP entrypoints from space separated "name:port" pairs
+TCP_ENTRYPOINTS_YAML=""
+for ENTRYPOINT in ${TRAEFIK_TCP_ENTRYPOINTS:-}; do
+    TCP_ENTRYPOINTS_YAML="${TCP_ENTRYPOINTS_YAML}  ${ENTRYPOINT%%:*}:
+    address: \":${ENTRYPOINT##*:}/tcp\"
+"
+done
+export TCP_ENTRYPOINTS_YAML
+
+awk '$0 == "  # ${TRAEFIK_TCP_ENTRYPOINTS}" { printf "%s", ENVIRON["TCP_ENTRYPOINTS_YAML"]; next } { print }' \
+    /etc/traefik/traefik.yml > /tmp/traefik.yml
+cat /tmp/traefik.yml > /etc/traefik/traefik.yml
+rm -f /tmp/traefik.yml
+
 # update static config as it's not allowed to both at once
 sed -i \
     -e "s/\${TRAEFIK_LOG_LEVEL}/${TRAEFIK_LOG_LEVEL:?empty or missing envvar}/g" \
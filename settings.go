@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const settingsFile = "settings.env"
+
+type Setting struct {
+	Key   string
+	Usage string
+}
+
+// defaults are taken from embedded compose.yaml, eg. ${KEY:-default}
+var knownSettings = []Setting{
+	{Key: "DOCKER_DEFAULT_IP", Usage: "IP used to bind DNS/Router ports"},
+	{Key: "TLS_SANS_EXTRA", Usage: "additional domain names (SAN) to be generated"},
+	{Key: "CERTS_RENEW_DAYS", Usage: "days before leaf certificate expiry to regenerate it on 'up'"},
+	{Key: "TRAEFIK_LOG_LEVEL", Usage: "log level of traefik"},
+	{Key: "TRAEFIK_VERSION", Usage: "version of traefik to run in docker"},
+	{Key: "PORTAINER_VERSION", Usage: "version of portainer to run in docker"},
+	{Key: "WHOAMI_VERSION", Usage: "version of whoami to run in docker"},
+	{Key: "CADDY_VERSION", Usage: "version of caddy serving catchall pages"},
+	{Key: "INSPECT_LIMIT", Usage: "number of last requests kept by inspect service"},
+	{Key: "HOOKS_LIMIT", Usage: "number of last webhooks kept per channel by hooks service"},
+	{Key: "ADDONS", Usage: "space separated addons merged into the stack, see 'addons list'"},
+	{Key: "TCP_ENTRYPOINTS", Usage: "named TCP entrypoints as space separated NAME:PORT pairs"},
+	{Key: "DOCKER_DEFAULT_IPV6", Usage: "IPv6 used to bind DNS/Router ports, enables dual-stack mode"},
+	{Key: "IPV6_SUBNET", Usage: "IPv6 subnet of stack network in dual-stack mode (required for docker < 27)"},
+}
+
+var ErrUnknownSetting = errors.New("unknown setting")
+
+func configDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		panic(err)
+	}
+	// mimic $XDG_CONFIG_HOME
+	return filepath.Join(home, ".config", appName)
+}
+
+func settingsPath() string {
+	return filepath.Join(configDir(), settingsFile)
+}
+
+func lookupSetting(key string) (Setting, error) {
+	for _, s := range knownSettings {
+		if s.Key == key {
+			return s, nil
+		}
+	}
+	return Setting{}, fmt.Errorf("%w %q", ErrUnknownSetting, key)
+}
+
+func composeDefault(key string) string {
+	data, err := stackFilesFS.ReadFile("compose.yaml")
+	if err != nil {
+		return ""
+	}
+
+	re := regexp.MustCompile(`\$\{` + regexp.QuoteMeta(key) + `:-([^}]*)\}`)
+	if m := re.FindSubmatch(data); m != nil {
+		return string(m[1])
+	}
+
+	return ""
+}
+
+func loadSettings() (map[string]string, error) {
+	return readEnvFile(settingsPath())
+}
+
+// readEnvFile parses KEY=VALUE lines, as written by docker compose .env files.
+func readEnvFile(path string) (map[string]string, error) {
+	values := map[string]string{}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return values, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+
+		values[strings.TrimSpace(key)] = unquoteSetting(strings.TrimSpace(value))
+	}
+
+	return values, scanner.Err()
+}
+
+// migrateStackEnv moves known settings of legacy .env file in stack directory
+// into settings file, as compose ignores it once --env-file is passed.
+func migrateStackEnv(dest string) error {
+	envPath := filepath.Join(dest, ".env")
+	if !fileExists(envPath) {
+		return nil
+	}
+
+	legacy, err := readEnvFile(envPath)
+	if err != nil {
+		return err
+	}
+
+	values, err := loadSettings()
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(legacy))
+	for key := range legacy {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if _, err := lookupSetting(key); err != nil {
+			fmt.Printf("WARN: %s: %s is not a known setting, skipping.\n", envPath, key)
+			continue
+		}
+
+		if _, ok := values[key]; ok {
+			fmt.Printf("WARN: %s: %s is already set in %s, skipping.\n", envPath, key, settingsPath())
+			continue
+		}
+
+		if err := validateSetting(key, legacy[key]); err != nil {
+			fmt.Printf("WARN: %s: %v, skipping.\n", envPath, err)
+			continue
+		}
+
+		values[key] = legacy[key]
+	}
+
+	if err := saveSettings(values); err != nil {
+		return err
+	}
+
+	if err := os.Rename(envPath, envPath+".migrated"); err != nil {
+		return err
+	}
+
+	fmt.Printf("INFO: migrated %s into %s, see 'settings list' command.\n", envPath, settingsPath())
+
+	return nil
+}
+
+func saveSettings(values map[string]string) error {
+	if err := os.MkdirAll(configDir(), 0755); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Managed by '%s settings' command.\n", appName)
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%s=%q\n", key, values[key])
+	}
+
+	path := settingsPath()
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, []byte(b.String()), 0o644); err != nil {
+		return fmt.Errorf("write temp file error: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("rename temp file error: %w", err)
+	}
+
+	return nil
+}
+
+func unquoteSetting(value string) string {
+	if len(value) >= 2 {
+		if (value[0] == '"' && value[len(value)-1] == '"') || (value[0] == '\'' && value[len(value)-1] == '\'') {
+			return value[1 : len(value)-1]
+		}
+	}
+	return value
+}
+
+// settingValue resolves effective value in the same order as docker compose does:
+// process environment, then settings file, then compose.yaml default.
+func settingValue(key string) (string, string) {
+	if value, ok := os.LookupEnv(key); ok {
+		return value, "environment"
+	}
+
+	if values, err := loadSettings(); err == nil {
+		if value, ok := values[key]; ok {
+			return value, "settings"
+		}
+	}
+
+	return composeDefault(key), "default"
+}
+
+func validateSetting(key, value string) error {
+	switch key {
+	case "DOCKER_DEFAULT_IP":
+		if ip := net.ParseIP(value); ip == nil || ip.To4() == nil {
+			return fmt.Errorf("%s: invalid IPv4 address %q", key, value)
+		}
+	case "DOCKER_DEFAULT_IPV6":
+		if ip := net.ParseIP(value); value != "" && (ip == nil || ip.To4() != nil) {
+			return fmt.Errorf("%s: invalid IPv6 address %q", key, value)
+		}
+	case "IPV6_SUBNET":
+		if ip, _, err := net.ParseCIDR(value); value != "" && (err != nil || ip.To4() != nil) {
+			return fmt.Errorf("%s: invalid IPv6 subnet %q", key, value)
+		}
+	case "CERTS_RENEW_DAYS":
+		if days, err := strconv.Atoi(value); err != nil || days < 1 {
+			return fmt.Errorf("%s: invalid number of days %q", key, value)
+		}
+	case "INSPECT_LIMIT", "HOOKS_LIMIT":
+		if limit, err := strconv.Atoi(value); err != nil || limit < 1 {
+			return fmt.Errorf("%s: invalid number of requests %q", key, value)
+		}
+	case "ADDONS":
+		if _, err := parseAddons(value); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	case "TCP_ENTRYPOINTS":
+		if _, err := parseTCPEntrypoints(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setSetting(key, value string) error {
+	if _, err := lookupSetting(key); err != nil {
+		return err
+	}
+
+	if err := validateSetting(key, value); err != nil {
+		return err
+	}
+
+	values, err := loadSettings()
+	if err != nil {
+		return err
+	}
+
+	values[key] = value
+
+	return saveSettings(values)
+}
+
+var cmdSettings = &cobra.Command{
+	Use:   "settings",
+	Short: "Manage persisted stack settings",
+}
+
+var cmdSettingsList = &cobra.Command{
+	Use:   "list",
+	Short: "List stack settings with effective values",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fmt.Printf("Settings file: %s\n\n", settingsPath())
+
+		for _, s := range knownSettings {
+			value, source := settingValue(s.Key)
+			fmt.Printf("%-20s %-40q (%s)\n", s.Key, value, source)
+		}
+
+		return nil
+	},
+}
+
+var cmdSettingsSet = &cobra.Command{
+	Use:   "set KEY=VALUE...",
+	Short: "Persist stack settings",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		values, err := loadSettings()
+		if err != nil {
+			return err
+		}
+
+		for _, arg := range args {
+			key, value, ok := strings.Cut(arg, "=")
+			if !ok {
+				return fmt.Errorf("invalid argument %q, expected KEY=VALUE", arg)
+			}
+
+			if _, err := lookupSetting(key); err != nil {
+				return err
+			}
+
+			if err := validateSetting(key, value); err != nil {
+				return err
+			}
+
+			values[key] = value
+		}
+
+		before := pinnedVersions()
+
+		if err := saveSettings(values); err != nil {
+			return err
+		}
+
+		if err := markVersionChanges(before); err != nil {
+			return err
+		}
+
+		fmt.Printf("Settings saved to %s\n", settingsPath())
+		fmt.Printf("\nINFO: please run 'up' command to apply changes.\n")
+
+		for _, arg := range args {
+			if strings.HasPrefix(arg, "DOCKER_DEFAULT_IPV6=") || strings.HasPrefix(arg, "IPV6_SUBNET=") {
+				fmt.Printf("INFO: network changes require 'down' command first.\n")
+				break
+			}
+		}
+
+		return nil
+	},
+}
+
+var cmdSettingsUnset = &cobra.Command{
+	Use:   "unset KEY...",
+	Short: "Reset stack settings to defaults",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		values, err := loadSettings()
+		if err != nil {
+			return err
+		}
+
+		for _, key := range args {
+			if _, err := lookupSetting(key); err != nil {
+				return err
+			}
+			delete(values, key)
+		}
+
+		before := pinnedVersions()
+
+		if err := saveSettings(values); err != nil {
+			return err
+		}
+
+		if err := markVersionChanges(before); err != nil {
+			return err
+		}
+
+		fmt.Printf("Settings saved to %s\n", settingsPath())
+		fmt.Printf("\nINFO: please run 'up' command to apply changes.\n")
+
+		return nil
+	},
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// stackHTTPClient returns client reaching stack routers directly, regardless of
+// host DNS setup: .test hosts are dialed to bind IP and stack Root CA is trusted.
+func stackHTTPClient(timeout time.Duration) (*http.Client, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+
+	caFile := filepath.Join(stackDir(), "certs", "rootCA.pem")
+
+	ca, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("stack Root CA is not available, run 'sync' first: %w", err)
+	}
+
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificate found in %s", caFile)
+	}
+
+	bindIP, _ := settingValue("DOCKER_DEFAULT_IP")
+	dialer := &net.Dialer{Timeout: timeout}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // stack is local
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err == nil && bindIP != "" && (host == "test" || strings.HasSuffix(host, ".test")) {
+			addr = net.JoinHostPort(bindIP, port)
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// kinds of stack file changes
+const (
+	StackFileAdd    = "add"
+	StackFileUpdate = "update"
+	StackFileRemove = "remove"
+)
+
+type StackChange struct {
+	Op   string
+	Path string
+	File *StackFileMeta // nil on remove
+}
+
+// isManagedStackPath reports whether file belongs to embedded stack, so it
+// could be removed once not embedded anymore. Other files are left intact.
+func isManagedStackPath(rel string) bool {
+	return rel == "compose.yaml" || strings.HasPrefix(rel, "services/") || strings.HasPrefix(rel, "addons/")
+}
+
+// stackService returns service name of services/<name>/ build context path.
+func stackService(rel string) string {
+	rest, ok := strings.CutPrefix(rel, "services/")
+	if !ok {
+		return ""
+	}
+	name, _, ok := strings.Cut(rest, "/")
+	if !ok {
+		return ""
+	}
+	return name
+}
+
+// planStackSync compares on-disk files with embedded ones by checksum.
+func planStackSync(dest string) ([]StackChange, error) {
+	var changes []StackChange
+
+	embedded := map[string]bool{}
+
+	for i := range stackFilesMeta {
+		file := &stackFilesMeta[i]
+		embedded[file.Path] = true
+
+		dst := filepath.Join(dest, file.Path)
+
+		if _, err := os.Stat(dst); err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				return nil, err
+			}
+			changes = append(changes, StackChange{Op: StackFileAdd, Path: file.Path, File: file})
+			continue
+		}
+
+		valid, err := verifySHA256(dst, file.Sha256)
+		if err != nil {
+			return nil, err
+		}
+		if !valid {
+			changes = append(changes, StackChange{Op: StackFileUpdate, Path: file.Path, File: file})
+		}
+	}
+
+	for _, dir := range []string{"services", "addons"} {
+		err := filepath.WalkDir(filepath.Join(dest, dir), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+
+			rel, err := filepath.Rel(dest, path)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+
+			if isManagedStackPath(rel) && !embedded[rel] {
+				changes = append(changes, StackChange{Op: StackFileRemove, Path: rel})
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	return changes, nil
+}
+
+// changedServices counts changed files per service build context,
+// skipping services removed from the stack.
+func changedServices(changes []StackChange) ([]string, map[string]int) {
+	embedded := map[string]bool{}
+	for _, f := range stackFilesMeta {
+		embedded[stackService(f.Path)] = true
+	}
+
+	counts := map[string]int{}
+	for _, c := range changes {
+		if name := stackService(c.Path); name != "" && embedded[name] {
+			counts[name]++
+		}
+	}
+
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, counts
+}
+
+// contextRebuildReasons flags services with changed build context files.
+func contextRebuildReasons(changes []StackChange) []RebuildReason {
+	services, counts := changedServices(changes)
+
+	reasons := make([]RebuildReason, 0, len(services))
+	for _, name := range services {
+		reasons = append(reasons, RebuildReason{
+			Kind:    RebuildContext,
+			Service: name,
+			Detail:  fmt.Sprintf("%d file(s) changed in services/%s/", counts[name], name),
+		})
+	}
+
+	return reasons
+}
+
+// pruneEmptyDirs removes empty parent directories of path up to root.
+func pruneEmptyDirs(root, path string) {
+	for dir := filepath.Dir(path); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return // not empty
+		}
+	}
+}
+
+// stackStagingDir and stackPreviousDir are siblings of stack directory,
+// so swapping them is an atomic rename within the same filesystem.
+func stackStagingDir(dest string) string  { return dest + ".staging" }
+func stackPreviousDir(dest string) string { return dest + ".previous" }
+
+// recoverStackDir cleans up leftovers of interrupted sync, bringing
+// previous directory back, if it was not replaced yet.
+func recoverStackDir(dest string) error {
+	previous := stackPreviousDir(dest)
+
+	if dirExists(previous) && !fileExists(filepath.Join(dest, stackInfoFile)) {
+		fmt.Printf("WARN: restoring stack directory from interrupted sync ...\n")
+
+		// stack directory might be re-created empty, eg. by previous version holding lock in it
+		if err := os.Remove(dest); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if err := os.Rename(previous, dest); err != nil {
+			return err
+		}
+	}
+
+	if err := os.RemoveAll(previous); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(stackStagingDir(dest))
+}
+
+// stageStackDir replicates stack directory into staging one, hard linking
+// files (or copying, if not supported) except skipped paths.
+func stageStackDir(dest, staging string, skip map[string]bool) error {
+	if err := os.MkdirAll(staging, 0755); err != nil {
+		return err
+	}
+
+	return filepath.WalkDir(dest, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) && path == dest {
+				return filepath.SkipDir // fresh stack
+			}
+			return err
+		}
+
+		rel, err := filepath.Rel(dest, path)
+		if err != nil {
+			return err
+		}
+		if skip[filepath.ToSlash(rel)] {
+			return nil
+		}
+
+		dst := filepath.Join(staging, rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			if err := os.MkdirAll(dst, 0755); err != nil {
+				return err
+			}
+			return os.Chmod(dst, info.Mode().Perm())
+		case d.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(target, dst)
+		case d.Type().IsRegular():
+			if os.Link(path, dst) == nil {
+				return nil
+			}
+			return copyFile(path, dst)
+		}
+
+		return nil // sockets and alike are not expected
+	})
+}
+
+// swapStackDir replaces stack directory with staging one, keeping previous
+// one until new one is in place.
+func swapStackDir(dest, staging string) error {
+	previous := stackPreviousDir(dest)
+
+	if dirExists(dest) {
+		if err := os.Rename(dest, previous); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(staging, dest); err != nil {
+		if dirExists(previous) {
+			if rollbackErr := os.Rename(previous, dest); rollbackErr != nil {
+				return fmt.Errorf("failed to swap stack directory: %w (rollback failed: %w)", err, rollbackErr)
+			}
+		}
+		return fmt.Errorf("failed to swap stack directory: %w", err)
+	}
+
+	return os.RemoveAll(previous)
+}
+
+// showStackSyncPlan prints what sync would change, without touching disk.
+func showStackSyncPlan(dest string) error {
+	changes, err := planStackSync(dest)
+	if err != nil {
+		return err
+	}
+
+	if len(changes) == 0 {
+		fmt.Printf("Stack files in %s directory are up to date.\n", dest)
+		return nil
+	}
+
+	var added, removed []string
+
+	for _, change := range changes {
+		oldName, newName := "a/"+change.Path, "b/"+change.Path
+
+		var oldData, newData []byte
+
+		if change.Op != StackFileAdd {
+			if oldData, err = os.ReadFile(filepath.Join(dest, change.Path)); err != nil {
+				return err
+			}
+		} else {
+			oldName = "/dev/null"
+			added = append(added, change.Path)
+		}
+
+		if change.Op != StackFileRemove {
+			if newData, err = stackFilesFS.ReadFile(change.Path); err != nil {
+				return err
+			}
+		} else {
+			newName = "/dev/null"
+			removed = append(removed, change.Path)
+		}
+
+		fmt.Print(unifiedDiff(oldName, newName, oldData, newData))
+	}
+
+	fmt.Printf("\n%d file(s) would change in %s directory.\n", len(changes), dest)
+
+	for _, path := range added {
+		fmt.Printf("  added:   %s\n", path)
+	}
+	for _, path := range removed {
+		fmt.Printf("  removed: %s\n", path)
+	}
+
+	// mirrors syncStack, fresh stack is built anyway
+	if fileExists(filepath.Join(dest, stackInfoFile)) {
+		reasons := contextRebuildReasons(changes)
+
+		if oldCompose, err := os.ReadFile(filepath.Join(dest, "compose.yaml")); err == nil {
+			newCompose, err := stackFilesFS.ReadFile("compose.yaml")
+			if err != nil {
+				return err
+			}
+			reasons = mergeRebuildReasons(reasons, buildArgsChanges(oldCompose, newCompose)...)
+		}
+
+		if len(reasons) > 0 {
+			fmt.Printf("\nINFO: services to be rebuilt:\n")
+			printRebuildReasons(reasons)
+		}
+	}
+
+	return nil
+}
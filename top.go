@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const traefikMetricsURL = "https://traefik.local.test/metrics"
+
+type promSample struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+}
+
+// parsePromLabels parses `{a="b",c="d"}` label set of prometheus text format.
+func parsePromLabels(s string) (map[string]string, error) {
+	labels := map[string]string{}
+
+	s = strings.TrimSpace(s)
+	for s != "" {
+		name, rest, ok := strings.Cut(s, "=")
+		if !ok || !strings.HasPrefix(rest, `"`) {
+			return nil, fmt.Errorf("malformed labels %q", s)
+		}
+
+		var value strings.Builder
+		i := 1
+		for ; i < len(rest) && rest[i] != '"'; i++ {
+			if rest[i] == '\\' && i+1 < len(rest) {
+				i++
+				if rest[i] == 'n' {
+					value.WriteByte('\n')
+					continue
+				}
+			}
+			value.WriteByte(rest[i])
+		}
+		if i >= len(rest) {
+			return nil, fmt.Errorf("unterminated label value %q", rest)
+		}
+
+		labels[strings.TrimSpace(name)] = value.String()
+		s = strings.TrimLeft(rest[i+1:], ", ")
+	}
+
+	return labels, nil
+}
+
+// parsePromText reads samples of prometheus text exposition format, skipping comments.
+func parsePromText(r io.Reader) ([]promSample, error) {
+	var samples []promSample
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		sample := promSample{Labels: map[string]string{}}
+
+		var rest string
+		if open := strings.IndexByte(line, '{'); open >= 0 {
+			end := strings.LastIndexByte(line, '}')
+			if end < open {
+				return nil, fmt.Errorf("malformed metric line %q", line)
+			}
+			labels, err := parsePromLabels(line[open+1 : end])
+			if err != nil {
+				return nil, err
+			}
+			sample.Name, sample.Labels, rest = line[:open], labels, line[end+1:]
+		} else {
+			sample.Name, rest, _ = strings.Cut(line, " ")
+		}
+
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("malformed metric line %q", line)
+		}
+
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed metric value %q: %w", line, err)
+		}
+		sample.Value = value
+
+		samples = append(samples, sample)
+	}
+
+	return samples, scanner.Err()
+}
+
+// TraefikMetrics holds counters of traefik prometheus endpoint used by 'top'.
+type TraefikMetrics struct {
+	OpenConnections float64
+	RouterRequests  map[string]float64 // total requests by router
+}
+
+func fetchTraefikMetrics(client *http.Client) (*TraefikMetrics, error) {
+	resp, err := client.Get(traefikMetricsURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", traefikMetricsURL, resp.Status)
+	}
+
+	samples, err := parsePromText(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := &TraefikMetrics{RouterRequests: map[string]float64{}}
+
+	for _, s := range samples {
+		switch s.Name {
+		case "traefik_open_connections":
+			metrics.OpenConnections += s.Value
+		case "traefik_router_requests_total":
+			metrics.RouterRequests[s.Labels["router"]] += s.Value
+		}
+	}
+
+	return metrics, nil
+}
+
+type topKey struct {
+	Host   string
+	Router string
+}
+
+type topSample struct {
+	At       time.Time
+	Status   int
+	Duration time.Duration
+}
+
+// TopStats aggregates access log entries within sliding window.
+type TopStats struct {
+	Window  time.Duration
+	Started time.Time
+	samples map[topKey][]topSample
+}
+
+type TopRow struct {
+	topKey
+	RPS       float64
+	ErrorRate float64 // share of 5xx responses
+	P50       time.Duration
+	P95       time.Duration
+}
+
+func NewTopStats(window time.Duration) *TopStats {
+	return &TopStats{Window: window, Started: time.Now(), samples: map[topKey][]topSample{}}
+}
+
+func (s *TopStats) Add(e AccessLogEntry, now time.Time) {
+	host, _, _ := strings.Cut(e.Host, ":")
+	key := topKey{Host: host, Router: e.Router}
+	s.samples[key] = append(s.samples[key], topSample{At: now, Status: e.Status, Duration: e.Duration})
+}
+
+// percentile uses nearest rank method on sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(rank, 0)]
+}
+
+// Rows drops samples out of window and computes stats of hosts seen so far.
+func (s *TopStats) Rows(now time.Time) []TopRow {
+	window := min(s.Window, now.Sub(s.Started))
+	if window < time.Second {
+		window = time.Second
+	}
+
+	rows := make([]TopRow, 0, len(s.samples))
+
+	for key, samples := range s.samples {
+		cut := 0
+		for cut < len(samples) && now.Sub(samples[cut].At) > s.Window {
+			cut++
+		}
+		samples = samples[cut:]
+		s.samples[key] = samples
+
+		row := TopRow{topKey: key}
+
+		if n := len(samples); n > 0 {
+			durations := make([]time.Duration, 0, n)
+			failed := 0
+			for _, sample := range samples {
+				durations = append(durations, sample.Duration)
+				if sample.Status >= 500 {
+					failed++
+				}
+			}
+			sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+			row.RPS = float64(n) / window.Seconds()
+			row.ErrorRate = float64(failed) / float64(n)
+			row.P50 = percentile(durations, 0.50)
+			row.P95 = percentile(durations, 0.95)
+		}
+
+		rows = append(rows, row)
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].RPS != rows[j].RPS {
+			return rows[i].RPS > rows[j].RPS
+		}
+		if rows[i].Host != rows[j].Host {
+			return rows[i].Host < rows[j].Host
+		}
+		return rows[i].Router < rows[j].Router
+	})
+
+	return rows
+}
+
+const topTableFormat = "%-30s %-24s %8s %6s %9s %9s %8s\n"
+
+func renderTop(w io.Writer, rows []TopRow, window time.Duration, metrics *TraefikMetrics, metricsErr error) {
+	// clear screen and move cursor home
+	fmt.Fprint(w, "\033[H\033[2J")
+
+	fmt.Fprintf(w, "localtest top - %s, window %s\n", time.Now().Format("15:04:05"), window)
+	if metricsErr != nil {
+		fmt.Fprintf(w, "Metrics: unavailable (%v)\n\n", metricsErr)
+	} else {
+		fmt.Fprintf(w, "Metrics: %.0f open connection(s)\n\n", metrics.OpenConnections)
+	}
+
+	fmt.Fprintf(w, topTableFormat, "HOST", "ROUTER", "REQ/S", "ERR%", "P50", "P95", "TOTAL")
+
+	if len(rows) == 0 {
+		fmt.Fprintf(w, "\nWaiting for requests ...\n")
+		return
+	}
+
+	for _, row := range rows {
+		total := "-"
+		if metrics != nil {
+			if value, ok := metrics.RouterRequests[row.Router]; ok {
+				total = strconv.FormatFloat(value, 'f', 0, 64)
+			}
+		}
+
+		fmt.Fprintf(w, topTableFormat,
+			row.Host, row.Router,
+			strconv.FormatFloat(row.RPS, 'f', 1, 64),
+			strconv.FormatFloat(100*row.ErrorRate, 'f', 1, 64),
+			renderDuration(row.P50), renderDuration(row.P95), total)
+	}
+}
+
+func runTop(ctx context.Context, window, interval time.Duration) error {
+	client, err := stackHTTPClient(interval)
+	if err != nil {
+		return err
+	}
+
+	entries := make(chan AccessLogEntry, 4096)
+	errs := make(chan error, 1)
+
+	go func() {
+		// tail=0 to count requests since start only
+		errs <- streamAccessLog(ctx, true, "", "0", func(e AccessLogEntry) error {
+			select {
+			case entries <- e:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	stats := NewTopStats(window)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errs:
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err == nil {
+				err = fmt.Errorf("traefik log stream ended, is stack running?")
+			}
+			return err
+		case e := <-entries:
+			stats.Add(e, time.Now())
+		case now := <-ticker.C:
+			metrics, metricsErr := fetchTraefikMetrics(client)
+			renderTop(os.Stdout, stats.Rows(now), window, metrics, metricsErr)
+		}
+	}
+}
+
+var cmdTop = &cobra.Command{
+	Use:   "top",
+	Short: "Live view of request rate, errors and latency per host and router",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		window, _ := cmd.Flags().GetDuration("window")
+		interval, _ := cmd.Flags().GetDuration("interval")
+
+		if window < time.Second || interval < 100*time.Millisecond {
+			return fmt.Errorf("window must be at least 1s and interval at least 100ms")
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		return runTop(ctx, window, interval)
+	},
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePromLabels(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "empty", input: "", want: map[string]string{}},
+		{name: "single", input: `code="200"`, want: map[string]string{"code": "200"}},
+		{
+			name:  "multiple with trailing comma",
+			input: `code="200",method="GET",router="app@docker",`,
+			want:  map[string]string{"code": "200", "method": "GET", "router": "app@docker"},
+		},
+		{name: "spaces around", input: ` code="200", method="GET" `, want: map[string]string{"code": "200", "method": "GET"}},
+		{name: "escaped quote", input: `path="/a\"b"`, want: map[string]string{"path": `/a"b`}},
+		{name: "escaped backslash", input: `path="C:\\tmp"`, want: map[string]string{"path": `C:\tmp`}},
+		{name: "escaped newline", input: `msg="a\nb"`, want: map[string]string{"msg": "a\nb"}},
+		{name: "special characters in value", input: `rule="Host(` + "`a`" + `) && Path(/{id})",x="1"`, want: map[string]string{"rule": "Host(`a`) && Path(/{id})", "x": "1"}},
+		{name: "missing value", input: `code`, wantErr: true},
+		{name: "unquoted value", input: `code=200`, wantErr: true},
+		{name: "unterminated value", input: `code="200`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePromLabels(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parsePromLabels(%q) = %v, want error", tt.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePromLabels(%q) error: %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePromLabels(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePromText(t *testing.T) {
+	input := strings.Join([]string{
+		"# HELP traefik_open_connections How many open connections exist.",
+		"# TYPE traefik_open_connections gauge",
+		`traefik_open_connections{entrypoint="https",protocol="TCP"} 3`,
+		"",
+		`traefik_router_requests_total{code="200",method="GET",protocol="http",router="app@docker",service="app@docker"} 1027`,
+		"process_start_time_seconds 1.7e+09",
+		"go_gc_duration_seconds_count 5 1700000000000",
+		`traefik_router_request_duration_seconds_bucket{le="+Inf",router="app@docker"} +Inf`,
+	}, "\n")
+
+	got, err := parsePromText(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parsePromText() error: %v", err)
+	}
+
+	want := []promSample{
+		{Name: "traefik_open_connections", Labels: map[string]string{"entrypoint": "https", "protocol": "TCP"}, Value: 3},
+		{Name: "traefik_router_requests_total", Labels: map[string]string{"code": "200", "method": "GET", "protocol": "http", "router": "app@docker", "service": "app@docker"}, Value: 1027},
+		{Name: "process_start_time_seconds", Labels: map[string]string{}, Value: 1.7e9},
+		{Name: "go_gc_duration_seconds_count", Labels: map[string]string{}, Value: 5},
+		{Name: "traefik_router_request_duration_seconds_bucket", Labels: map[string]string{"le": "+Inf", "router": "app@docker"}, Value: math.Inf(1)},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parsePromText() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestParsePromTextMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{name: "missing value", line: "traefik_open_connections"},
+		{name: "missing value after labels", line: `traefik_open_connections{entrypoint="https"}`},
+		{name: "invalid value", line: "traefik_open_connections three"},
+		{name: "unclosed labels", line: `traefik_open_connections{entrypoint="https" 3`},
+		{name: "unterminated label value", line: `traefik_open_connections{entrypoint="https} 3`},
+		{name: "unquoted label value", line: `traefik_open_connections{entrypoint=https} 3`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := parsePromText(strings.NewReader(tt.line + "\n")); err == nil {
+				t.Errorf("parsePromText(%q) = %+v, want error", tt.line, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,482 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const defaultRegistryURL = "https://registry-1.docker.io"
+
+type Component struct {
+	Name    string
+	Setting string // empty, if version can't be pinned
+	Repo    string // registry repository
+	Suffix  string // tag suffix appended to version, eg. -alpine
+	Service string // compose service running the component
+}
+
+var stackComponents = []Component{
+	{Name: "traefik", Setting: "TRAEFIK_VERSION", Repo: "library/traefik", Service: "traefik"},
+	{Name: "portainer", Setting: "PORTAINER_VERSION", Repo: "portainer/portainer-ce", Suffix: "-alpine", Service: "portainer"},
+	{Name: "whoami", Setting: "WHOAMI_VERSION", Repo: "traefik/whoami", Service: "whoami"},
+	{Name: "caddy", Setting: "CADDY_VERSION", Repo: "library/caddy", Suffix: "-alpine", Service: "catchall"},
+	{Name: "mkcert", Service: "mkcert"}, // installed from alpine edge/testing packages
+}
+
+var ErrUnknownComponent = errors.New("unknown component")
+var ErrTagNotFound = errors.New("tag not found")
+
+func lookupComponent(name string) (Component, error) {
+	for _, c := range stackComponents {
+		if c.Name == name {
+			return c, nil
+		}
+	}
+	return Component{}, fmt.Errorf("%w %q", ErrUnknownComponent, name)
+}
+
+func (c Component) Pinned() (string, string) {
+	if c.Setting == "" {
+		return "", "apk edge/testing"
+	}
+	return settingValue(c.Setting)
+}
+
+// Running reports version of the component currently used by the stack.
+func (c Component) Running() string {
+	if c.Setting == "" {
+		out, err := exec.Command("docker", "run", "--rm", "--network", "none", "--entrypoint", "mkcert", stackImage(c.Service), "-version").Output()
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	out, err := dockerComposeOutput("ps", "-q", "--status", "running", c.Service)
+	if err != nil {
+		return ""
+	}
+
+	ids := strings.Fields(string(out))
+	if len(ids) == 0 {
+		return ""
+	}
+
+	out, err = exec.Command("docker", "inspect", "--format",
+		`{{index .Config.Labels "localtest.version"}}|{{.Config.Image}}`, ids[0]).Output()
+	if err != nil {
+		return ""
+	}
+
+	label, image, _ := strings.Cut(strings.TrimSpace(string(out)), "|")
+	if label != "" {
+		return label
+	}
+
+	if idx := strings.LastIndex(image, ":"); idx >= 0 && !strings.Contains(image[idx:], "/") {
+		return strings.TrimSuffix(image[idx+1:], c.Suffix)
+	}
+
+	return ""
+}
+
+// versionPattern derives tag shape from pinned version, eg. v3.6.1 -> ^v\d+\.\d+\.\d+$
+func versionPattern(version string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, part := range regexp.MustCompile(`\d+|\D+`).FindAllString(version, -1) {
+		if part[0] >= '0' && part[0] <= '9' {
+			b.WriteString(`\d+`)
+		} else {
+			b.WriteString(regexp.QuoteMeta(part))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// compareVersions compares numeric parts of versions of the same shape.
+func compareVersions(a, b string) int {
+	re := regexp.MustCompile(`\d+`)
+	pa, pb := re.FindAllString(a, -1), re.FindAllString(b, -1)
+
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		na, _ := strconv.Atoi(pa[i])
+		nb, _ := strconv.Atoi(pb[i])
+		if na != nb {
+			return na - nb
+		}
+	}
+
+	return len(pa) - len(pb)
+}
+
+// Latest picks the newest tag of the same shape as pinned version.
+func (c Component) Latest(reg *Registry) (string, error) {
+	pinned, _ := c.Pinned()
+
+	tags, err := reg.Tags(c.Repo)
+	if err != nil {
+		return "", err
+	}
+
+	pattern := versionPattern(pinned)
+
+	latest := ""
+	for _, tag := range tags {
+		version, ok := strings.CutSuffix(tag, c.Suffix)
+		if !ok || !pattern.MatchString(version) {
+			continue
+		}
+		if latest == "" || compareVersions(version, latest) > 0 {
+			latest = version
+		}
+	}
+
+	return latest, nil
+}
+
+// Registry is minimal docker registry v2 API client with anonymous bearer auth.
+type Registry struct {
+	URL    string
+	Client *http.Client
+	tokens map[string]string
+}
+
+func NewRegistry(rawURL string) *Registry {
+	return &Registry{
+		URL:    strings.TrimSuffix(rawURL, "/"),
+		Client: &http.Client{Timeout: 10 * time.Second},
+		tokens: map[string]string{},
+	}
+}
+
+var authParamRe = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+func (r *Registry) token(challenge string) (string, error) {
+	params := map[string]string{}
+	for _, m := range authParamRe.FindAllStringSubmatch(challenge, -1) {
+		params[m[1]] = m[2]
+	}
+
+	realm := params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("unsupported auth challenge %q", challenge)
+	}
+
+	q := url.Values{}
+	if params["service"] != "" {
+		q.Set("service", params["service"])
+	}
+	if params["scope"] != "" {
+		q.Set("scope", params["scope"])
+	}
+
+	resp, err := r.Client.Get(realm + "?" + q.Encode())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request failed: %s", resp.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
+
+// do sends request, negotiating bearer token per repository when challenged.
+func (r *Registry) do(method, repo, target string, header http.Header) (*http.Response, error) {
+	if !strings.HasPrefix(target, "http") {
+		target = r.URL + target
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(method, target, nil)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		if token := r.tokens[repo]; token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		resp, err := r.Client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		challenge := resp.Header.Get("WWW-Authenticate")
+		if resp.StatusCode != http.StatusUnauthorized || attempt > 0 || !strings.HasPrefix(challenge, "Bearer ") {
+			return resp, nil
+		}
+		resp.Body.Close()
+
+		if r.tokens[repo], err = r.token(challenge); err != nil {
+			return nil, err
+		}
+	}
+}
+
+var linkNextRe = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+func (r *Registry) Tags(repo string) ([]string, error) {
+	var tags []string
+
+	next := "/v2/" + repo + "/tags/list?n=1000"
+	for next != "" {
+		resp, err := r.do(http.MethodGet, repo, next, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("listing %s tags failed: %s", repo, resp.Status)
+		}
+
+		var body struct {
+			Tags []string `json:"tags"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, body.Tags...)
+
+		next = ""
+		if m := linkNextRe.FindStringSubmatch(resp.Header.Get("Link")); m != nil {
+			next = m[1]
+		}
+	}
+
+	return tags, nil
+}
+
+func (r *Registry) TagExists(repo, tag string) error {
+	header := http.Header{}
+	header.Set("Accept", strings.Join([]string{
+		"application/vnd.oci.image.index.v1+json",
+		"application/vnd.docker.distribution.manifest.list.v2+json",
+		"application/vnd.oci.image.manifest.v1+json",
+		"application/vnd.docker.distribution.manifest.v2+json",
+	}, ", "))
+
+	resp, err := r.do(http.MethodHead, repo, "/v2/"+repo+"/manifests/"+tag, header)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %s:%s", ErrTagNotFound, repo, tag)
+	default:
+		return fmt.Errorf("checking %s:%s failed: %s", repo, tag, resp.Status)
+	}
+}
+
+// pinnedVersions snapshots effective versions, to detect changes later.
+func pinnedVersions() map[string]string {
+	versions := map[string]string{}
+	for _, c := range stackComponents {
+		versions[c.Name], _ = c.Pinned()
+	}
+	return versions
+}
+
+// markVersionChanges sets rebuild marker, if any pinned version has changed.
+func markVersionChanges(before map[string]string) error {
+	var reasons []RebuildReason
+
+	for _, c := range stackComponents {
+		after, _ := c.Pinned()
+		if after != before[c.Name] {
+			fmt.Printf("INFO: %s version changed %s -> %s\n", c.Name, before[c.Name], after)
+			reasons = append(reasons, RebuildReason{
+				Kind:    RebuildArgs,
+				Service: c.Service,
+				Detail:  fmt.Sprintf("%s %s -> %s", c.Setting, before[c.Name], after),
+			})
+		}
+	}
+
+	if len(reasons) == 0 {
+		return nil
+	}
+
+	return markStackRebuild(reasons...)
+}
+
+// registryFromFlags returns registry client and whether it's reachable,
+// explicitly given registry (eg. local stand-in) is assumed to be.
+func registryFromFlags(cmd *cobra.Command) (*Registry, bool) {
+	rawURL, _ := cmd.Flags().GetString("registry")
+	return NewRegistry(rawURL), cmd.Flags().Changed("registry") || HasInternetConnection()
+}
+
+var cmdVersions = &cobra.Command{
+	Use:   "versions",
+	Short: "Manage versions of stack components",
+}
+
+var cmdVersionsList = &cobra.Command{
+	Use:   "list",
+	Short: "List pinned, running and available component versions",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		reg, online := registryFromFlags(cmd)
+
+		fmt.Printf("%-10s %-28s %-12s %s\n", "COMPONENT", "PINNED", "RUNNING", "LATEST")
+
+		for _, c := range stackComponents {
+			pinned, source := c.Pinned()
+
+			running := c.Running()
+			if running == "" {
+				running = "-"
+			}
+
+			latest := "-"
+			if c.Repo != "" && online {
+				if v, err := c.Latest(reg); err != nil {
+					latest = fmt.Sprintf("error: %v", err)
+				} else if v != "" {
+					latest = v
+				}
+			}
+
+			fmt.Printf("%-10s %-28s %-12s %s\n", c.Name, fmt.Sprintf("%s (%s)", pinned, source), running, latest)
+		}
+
+		if !online {
+			fmt.Printf("\nWARN: running in OFFLINE mode, available versions are unknown.\n")
+		}
+
+		return nil
+	},
+}
+
+var cmdVersionsSet = &cobra.Command{
+	Use:   "set <component> <version>",
+	Short: "Pin component version, validated against registry when online",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := lookupComponent(args[0])
+		if err != nil {
+			return err
+		}
+
+		if c.Setting == "" {
+			return fmt.Errorf("%s version can't be pinned, it's installed from alpine packages", c.Name)
+		}
+
+		version := strings.TrimSuffix(args[1], c.Suffix)
+
+		if reg, online := registryFromFlags(cmd); online {
+			if err := reg.TagExists(c.Repo, version+c.Suffix); err != nil {
+				return err
+			}
+		} else {
+			fmt.Printf("WARN: running in OFFLINE mode, %s:%s is not validated\n", c.Repo, version+c.Suffix)
+		}
+
+		if _, source := c.Pinned(); source == "environment" {
+			fmt.Printf("WARN: %s is set in environment, which takes precedence over settings.\n", c.Setting)
+		}
+
+		before := pinnedVersions()
+
+		if err := setSetting(c.Setting, version); err != nil {
+			return err
+		}
+
+		if err := markVersionChanges(before); err != nil {
+			return err
+		}
+
+		fmt.Printf("Settings saved to %s\n", settingsPath())
+		fmt.Printf("\nINFO: please run 'up' command to apply changes.\n")
+
+		return nil
+	},
+}
+
+var cmdVersionsCheck = &cobra.Command{
+	Use:   "check",
+	Short: "Validate pinned versions and detect drift from running ones",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		reg, online := registryFromFlags(cmd)
+
+		problems := 0
+
+		for _, c := range stackComponents {
+			if c.Setting == "" {
+				continue
+			}
+
+			pinned, _ := c.Pinned()
+
+			if online {
+				if err := reg.TagExists(c.Repo, pinned+c.Suffix); err != nil {
+					fmt.Printf("FAIL  %-10s %v\n", c.Name, err)
+					problems++
+					continue
+				}
+			}
+
+			if running := c.Running(); running != "" && running != pinned {
+				fmt.Printf("DRIFT %-10s pinned %s, running %s\n", c.Name, pinned, running)
+				problems++
+				continue
+			}
+
+			if online {
+				if latest, err := c.Latest(reg); err == nil && latest != "" && compareVersions(latest, pinned) > 0 {
+					fmt.Printf("OK    %-10s %s (newer %s available)\n", c.Name, pinned, latest)
+					continue
+				}
+			}
+
+			fmt.Printf("OK    %-10s %s\n", c.Name, pinned)
+		}
+
+		if !online {
+			fmt.Printf("\nWARN: running in OFFLINE mode, pinned versions are not validated.\n")
+		}
+
+		if problems > 0 {
+			return fmt.Errorf("%d problem(s) found, run 'versions set' or 'up' to fix", problems)
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// stackImage returns image name built by docker compose for the stack service.
+func stackImage(service string) string {
+	return appName + "-" + service
+}
+
+func volumeHelperCommand(image, volume, target string, readOnly bool, script string) *exec.Cmd {
+	mount := volume + ":" + target
+	if readOnly {
+		mount += ":ro"
+	}
+
+	args := []string{"run", "--rm", "-i", "--network", "none", "-v", mount, "--entrypoint", "sh", image, "-ec", script}
+
+	return exec.Command("docker", args...)
+}
+
+func helperError(volume string, err error, stderr *bytes.Buffer) error {
+	msg := strings.TrimSpace(stderr.String())
+	if msg == "" {
+		return fmt.Errorf("volume %q helper failed: %w", volume, err)
+	}
+	return fmt.Errorf("volume %q helper failed: %w: %s", volume, err, msg)
+}
+
+// runVolumeHelper runs shell script in a throwaway container of the stack image
+// with volume mounted at target path, so it works regardless of the stack state.
+func runVolumeHelper(image, volume, target string, readOnly bool, stdin []byte, script string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := volumeHelperCommand(image, volume, target, readOnly, script)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, helperError(volume, err, &stderr)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// ensureStackImage builds stack service image, unless it exists already.
+func ensureStackImage(service string) error {
+	if exec.Command("docker", "image", "inspect", stackImage(service)).Run() == nil {
+		return nil
+	}
+	return runDockerCompose("build", service)
+}
+
+func volumeExists(volume string) bool {
+	return exec.Command("docker", "volume", "inspect", volume).Run() == nil
+}
+
+// exportVolume streams volume content as tar entries to fn.
+func exportVolume(volume string, fn func(hdr *tar.Header, r io.Reader) error) error {
+	var stderr bytes.Buffer
+
+	cmd := volumeHelperCommand(stackImage("mkcert"), volume, "/volume", true, "tar -C /volume -cf - .")
+	cmd.Stderr = &stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(stdout)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			err = fn(hdr, tr)
+		}
+		if err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return err
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return helperError(volume, err, &stderr)
+	}
+
+	return nil
+}
+
+// importVolume replaces volume content with tar archive read from r.
+func importVolume(volume string, r io.Reader) error {
+	var stderr bytes.Buffer
+
+	cmd := volumeHelperCommand(stackImage("mkcert"), volume, "/volume", false,
+		"find /volume -mindepth 1 -delete; tar -C /volume -xf -")
+	cmd.Stdin = r
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return helperError(volume, err, &stderr)
+	}
+
+	return nil
+}
+
+// stackServiceRunning reports whether any container of the service is running.
+func stackServiceRunning(service string) bool {
+	out, err := dockerComposeOutput("ps", "-q", "--status", "running", service)
+	if err != nil {
+		return false
+	}
+	return len(bytes.TrimSpace(out)) > 0
+}